@@ -0,0 +1,150 @@
+package gherkin
+
+// Position of a node within a feature file. Both Line and Column are 1-based.
+type Location struct {
+    Line int
+    Column int
+}
+
+// A '#' comment line.
+type Comment struct {
+    Location
+    Text string
+}
+
+// A single @tag.
+type Tag struct {
+    Location
+    Name string
+}
+
+// The root of a parsed feature file.
+type Feature struct {
+    Location
+    Language string
+    Tags []*Tag
+    Keyword string
+    Name string
+    Description string
+    Background *Background
+    ScenarioDefinitions []Definition
+    Rules []*Rule
+    Comments []*Comment
+}
+
+// Groups the scenarios which illustrate a single business rule. Its
+// Background runs after the Feature's.
+type Rule struct {
+    Location
+    Tags []*Tag
+    Keyword string
+    Name string
+    Description string
+    Background *Background
+    ScenarioDefinitions []Definition
+}
+
+// Steps which run before every scenario in the same Feature or Rule.
+type Background struct {
+    Location
+    Keyword string
+    Name string
+    Description string
+    Steps []*Step
+}
+
+// Fields shared by Scenario and ScenarioOutline.
+type ScenarioDefinition struct {
+    Location
+    Tags []*Tag
+    Keyword string
+    Name string
+    Description string
+    Steps []*Step
+}
+
+func (d *ScenarioDefinition) Definition() *ScenarioDefinition {
+    return d
+}
+
+// Implemented by *Scenario and *ScenarioOutline, so that both may be
+// kept in Feature.ScenarioDefinitions in the order they were written.
+type Definition interface {
+    Definition() *ScenarioDefinition
+}
+
+type Scenario struct {
+    ScenarioDefinition
+}
+
+type ScenarioOutline struct {
+    ScenarioDefinition
+    Examples []*Examples
+}
+
+// A table of values used to generate scenarios from a ScenarioOutline.
+type Examples struct {
+    Location
+    Tags []*Tag
+    Keyword string
+    Name string
+    Description string
+    TableHeader *TableRow
+    TableBody []*TableRow
+}
+
+type Step struct {
+    Location
+    Keyword string
+    // What the keyword means, regardless of the language it is in.
+    KeywordType KeywordType
+    Text string
+    DataTable *DataTable
+    DocString *DocString
+}
+
+// Given is a context, When an action and Then an outcome. And and But are
+// conjunctions, which continue whatever the previous step was. A keyword
+// such as "*" which could be any of them is unknown.
+type KeywordType string
+
+const (
+    UnknownKeyword KeywordType = "Unknown"
+    ContextKeyword KeywordType = "Context"
+    ActionKeyword KeywordType = "Action"
+    OutcomeKeyword KeywordType = "Outcome"
+    ConjunctionKeyword KeywordType = "Conjunction"
+)
+
+type DataTable struct {
+    Location
+    Rows []*TableRow
+}
+
+type TableRow struct {
+    Location
+    Cells []*TableCell
+}
+
+type TableCell struct {
+    Location
+    Value string
+}
+
+// A block of text between two """ or ``` delimiters. ContentType is
+// whatever follows the opening delimiter, such as "json".
+type DocString struct {
+    Location
+    Delimiter string
+    ContentType string
+    Content string
+}
+
+// The values of each cell in the row.
+func (row *TableRow) Values() []string {
+    values := make([]string, len(row.Cells))
+    for i, c := range row.Cells {
+        values[i] = c.Value
+    }
+    return values
+}
@@ -0,0 +1,32 @@
+package gherkin
+
+import (
+    "fmt"
+    "strings"
+)
+
+// A problem found while parsing a feature file.
+type ParseError struct {
+    File string
+    Location
+    Message string
+}
+
+func (e *ParseError) Error() string {
+    if len(e.File) > 0 {
+        return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+    }
+    return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// Every ParseError found in a single file, in the order they occur.
+// This is the error returned by Parse() and ParseFile().
+type ParseErrors []*ParseError
+
+func (errs ParseErrors) Error() string {
+    msgs := make([]string, len(errs))
+    for i, e := range errs {
+        msgs[i] = e.Error()
+    }
+    return strings.Join(msgs, "\n")
+}
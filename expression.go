@@ -0,0 +1,243 @@
+package gherkin
+
+import (
+    "fmt"
+    "reflect"
+    re "regexp"
+    "strings"
+)
+
+// A {name} which may appear in a Cucumber Expression. The regexp must not
+// contain capturing groups, since each parameter is a single capture.
+type parameterType struct {
+    name string
+    regexp string
+    // Converts the captured text. If nil, the text is used as it is.
+    transform func(string) (interface{}, error)
+    // The type transform returns, which step definitions must accept.
+    // If nil, the text is converted to whatever the argument is.
+    typ reflect.Type
+}
+
+const intRegexp = `-?\d+`
+const floatRegexp = `[-+]?\d*\.?\d+(?:[eE][-+]?\d+)?`
+
+var builtinParameterTypes = map[string]*parameterType{
+    "int": {"int", intRegexp, nil, nil},
+    "byte": {"byte", intRegexp, nil, nil},
+    "short": {"short", intRegexp, nil, nil},
+    "long": {"long", intRegexp, nil, nil},
+    "float": {"float", floatRegexp, nil, nil},
+    "double": {"double", floatRegexp, nil, nil},
+    "word": {"word", `[^\s]+`, nil, nil},
+    "string": {"string", `"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'`, unquote, nil},
+    "": {"", `.*`, nil, nil},
+}
+
+func copyParameterTypes(types map[string]*parameterType) map[string]*parameterType {
+    result := map[string]*parameterType{}
+    for name, pt := range types {
+        result[name] = pt
+    }
+    return result
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Builds a parameter type from a transformer of the form
+// func(string) (T, error) or func(string) T.
+func newParameterType(name, pattern string, transformer interface{}) (*parameterType, error) {
+    if name == "" || strings.ContainsAny(name, "{}()/\\ \t") {
+        return nil, fmt.Errorf("%q is not a valid parameter type name", name)
+    }
+    r, err := re.Compile(pattern)
+    if err != nil {
+        return nil, fmt.Errorf("parameter type {%s} has an invalid regexp: %v", name, err)
+    }
+    if r.NumSubexp() > 0 {
+        return nil, fmt.Errorf("parameter type {%s} must not use capture groups in %q; use (?:...) instead", name, pattern)
+    }
+    fv := reflect.ValueOf(transformer)
+    ft := reflect.TypeOf(transformer)
+    if ft == nil || ft.Kind() != reflect.Func || ft.NumIn() != 1 || ft.In(0).Kind() != reflect.String ||
+        ft.NumOut() < 1 || ft.NumOut() > 2 || (ft.NumOut() == 2 && ft.Out(1) != errorType) {
+        return nil, fmt.Errorf("the transformer for parameter type {%s} must be func(string) (T, error) or func(string) T, not %v", name, ft)
+    }
+    transform := func(s string) (interface{}, error) {
+        out := fv.Call([]reflect.Value{reflect.ValueOf(s).Convert(ft.In(0))})
+        if len(out) == 2 && !out[1].IsNil() {
+            return nil, out[1].Interface().(error)
+        }
+        return out[0].Interface(), nil
+    }
+    return &parameterType{name: name, regexp: pattern, transform: transform, typ: ft.Out(0)}, nil
+}
+
+// The parameter type which converts captures to t, for step definitions
+// given as regular expressions. Go's own types, such as string and int,
+// and time.Duration are always left to convertArg(), so that defining a
+// parameter type never changes the steps which already use them.
+func parameterTypeFor(t reflect.Type, types map[string]*parameterType) (*parameterType, error) {
+    if t.PkgPath() == "" && isConvertible(t) || t == durationType {
+        return nil, nil
+    }
+    var found *parameterType
+    for _, pt := range types {
+        if pt.typ != t {
+            continue
+        }
+        if found != nil {
+            return nil, fmt.Errorf("both {%s} and {%s} convert to %v", found.name, pt.name, t)
+        }
+        found = pt
+    }
+    return found, nil
+}
+
+// Removes the quotes matched by {string}, and the escapes of any quotes
+// within it.
+func unquote(s string) (interface{}, error) {
+    quote := s[:1]
+    return strings.Replace(s[1:len(s)-1], `\` + quote, quote, -1), nil
+}
+
+var expressionParameter = re.MustCompile(`(^|[^\\])\{([^{}\s]*)\}`)
+
+// Patterns are Cucumber Expressions if they use one of the parameter
+// types, such as "I have {int} cukes", and are not anchored like a
+// regular expression. Anything else is a regular expression.
+func looksLikeExpression(pattern string, types map[string]*parameterType) bool {
+    if strings.HasPrefix(pattern, "^") || strings.HasSuffix(pattern, "$") {
+        return false
+    }
+    for _, m := range expressionParameter.FindAllStringSubmatch(pattern, -1) {
+        if _, ok := types[m[2]]; ok {
+            return true
+        }
+    }
+    return false
+}
+
+// Translates a Cucumber Expression into an anchored regular expression,
+// along with the parameter type of each of its capture groups.
+//
+//     I have {int} cucumber(s) in my belly/stomach
+//
+// has an {int} parameter, optional text "(s)" and the alternatives
+// "belly" or "stomach". A backslash escapes any of "{}()/\".
+func compileExpression(expr string, types map[string]*parameterType) (string, []*parameterType, error) {
+    var out strings.Builder
+    params := []*parameterType{}
+    out.WriteString("^")
+    for _, chunk := range splitOnSpace(expr) {
+        if strings.TrimSpace(chunk) == "" {
+            out.WriteString(re.QuoteMeta(chunk))
+            continue
+        }
+        alternatives := splitUnescaped(chunk, '/')
+        if len(alternatives) == 1 {
+            compiled, chunkParams, err := compileExpressionText(expr, chunk, types, true)
+            if err != nil {
+                return "", nil, err
+            }
+            out.WriteString(compiled)
+            params = append(params, chunkParams...)
+            continue
+        }
+        compiledAlternatives := []string{}
+        for _, alt := range alternatives {
+            if alt == "" {
+                return "", nil, fmt.Errorf("Cucumber expression %q has an empty alternative", expr)
+            }
+            compiled, _, err := compileExpressionText(expr, alt, types, false)
+            if err != nil {
+                return "", nil, err
+            }
+            compiledAlternatives = append(compiledAlternatives, compiled)
+        }
+        out.WriteString("(?:" + strings.Join(compiledAlternatives, "|") + ")")
+    }
+    out.WriteString("$")
+    return out.String(), params, nil
+}
+
+// Splits the text into words and the runs of whitespace between them.
+func splitOnSpace(s string) []string {
+    chunks := []string{}
+    start := 0
+    for i := 1; i <= len(s); i++ {
+        if i == len(s) || isSpace(s[i]) != isSpace(s[start]) {
+            chunks = append(chunks, s[start:i])
+            start = i
+        }
+    }
+    return chunks
+}
+
+func isSpace(c byte) bool {
+    return c == ' ' || c == '\t'
+}
+
+func splitUnescaped(s string, sep byte) []string {
+    parts := []string{}
+    start := 0
+    for i := 0; i < len(s); i++ {
+        if s[i] == '\\' {
+            i++
+        } else if s[i] == sep {
+            parts = append(parts, s[start:i])
+            start = i + 1
+        }
+    }
+    return append(parts, s[start:])
+}
+
+// Compiles a single word of the expression, which contains no whitespace
+// and no alternation.
+func compileExpressionText(expr, text string, types map[string]*parameterType, allowParams bool) (string, []*parameterType, error) {
+    var out strings.Builder
+    params := []*parameterType{}
+    for i := 0; i < len(text); i++ {
+        switch c := text[i]; c {
+        case '\\':
+            if i+1 < len(text) {
+                i++
+                out.WriteString(re.QuoteMeta(text[i:i+1]))
+            }
+        case '(':
+            end := strings.IndexByte(text[i:], ')')
+            if end < 0 {
+                return "", nil, fmt.Errorf("Cucumber expression %q has an unmatched '('", expr)
+            }
+            optional := text[i+1 : i+end]
+            if strings.ContainsAny(optional, "({") {
+                return "", nil, fmt.Errorf("Cucumber expression %q may not nest parameters or optional text within optional text", expr)
+            }
+            compiled, _, err := compileExpressionText(expr, optional, types, false)
+            if err != nil {
+                return "", nil, err
+            }
+            out.WriteString("(?:" + compiled + ")?")
+            i += end
+        case '{':
+            end := strings.IndexByte(text[i:], '}')
+            if end < 0 {
+                return "", nil, fmt.Errorf("Cucumber expression %q has an unmatched '{'", expr)
+            }
+            if !allowParams {
+                return "", nil, fmt.Errorf("Cucumber expression %q may not use parameters within optional text or alternatives", expr)
+            }
+            name := text[i+1 : i+end]
+            pt, ok := types[name]
+            if !ok {
+                return "", nil, fmt.Errorf("Cucumber expression %q uses undefined parameter type {%s}", expr, name)
+            }
+            out.WriteString("(" + pt.regexp + ")")
+            params = append(params, pt)
+            i += end
+        default:
+            out.WriteString(re.QuoteMeta(text[i:i+1]))
+        }
+    }
+    return out.String(), params, nil
+}
@@ -0,0 +1,172 @@
+package gherkin
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "testing"
+    . "github.com/tychofreeman/go-matchers"
+)
+
+func expressionMatches(t *testing.T, expr, text string) []string {
+    p, _, err := compileExpression(expr, builtinParameterTypes)
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    sd, _ := createstepdef("^" + p[1:], nil, builtinParameterTypes)
+    return sd.r.FindStringSubmatch(text)
+}
+
+func TestExpressionMatchesParameters(t *testing.T) {
+    m := expressionMatches(t, "I have {int} cukes in my {word}", "I have 42 cukes in my belly")
+
+    AssertThat(t, m[1:], Equals([]string{"42", "belly"}))
+}
+
+func TestExpressionIsAnchored(t *testing.T) {
+    AssertThat(t, len(expressionMatches(t, "I have {int} cukes", "I have 42 cukes today")), Equals(0))
+}
+
+func TestExpressionSupportsOptionalText(t *testing.T) {
+    AssertThat(t, len(expressionMatches(t, "I have {int} cucumber(s)", "I have 1 cucumber")), Equals(2))
+    AssertThat(t, len(expressionMatches(t, "I have {int} cucumber(s)", "I have 2 cucumbers")), Equals(2))
+}
+
+func TestExpressionSupportsAlternation(t *testing.T) {
+    AssertThat(t, len(expressionMatches(t, "in my belly/stomach", "in my stomach")), Equals(1))
+    AssertThat(t, len(expressionMatches(t, "in my belly/stomach", "in my belly")), Equals(1))
+    AssertThat(t, len(expressionMatches(t, "in my belly/stomach", "in my head")), Equals(0))
+}
+
+func TestExpressionEscapesRegexpCharacters(t *testing.T) {
+    AssertThat(t, len(expressionMatches(t, `it costs $5.00 \(or more\)`, "it costs $5.00 (or more)")), Equals(1))
+    AssertThat(t, len(expressionMatches(t, `it costs $5.00`, "it costs $5x00")), Equals(0))
+}
+
+func TestExpressionReportsUnknownParameterType(t *testing.T) {
+    _, _, err := compileExpression("I have {colour} cukes", builtinParameterTypes)
+
+    AssertThat(t, err != nil, IsTrue)
+}
+
+func TestRegisterStepDefDetectsExpressions(t *testing.T) {
+    AssertThat(t, looksLikeExpression("I have {int} cukes", builtinParameterTypes), IsTrue)
+    AssertThat(t, looksLikeExpression(`^I have (\d{2}) cukes$`, builtinParameterTypes), IsFalse)
+    AssertThat(t, looksLikeExpression(`\d{2}`, builtinParameterTypes), IsFalse)
+    AssertThat(t, looksLikeExpression("(thing)", builtinParameterTypes), IsFalse)
+}
+
+func TestExpressionStepDefinitionsReceiveTypedArguments(t *testing.T) {
+    g := createWriterlessRunner()
+    var count int
+    var what string
+    var price float64
+    g.RegisterStepDef("I have {int} {string} at {float}", func(c int, w string, p float64) {
+        count, what, price = c, w, p
+    })
+    g.Execute(`Feature:
+        Scenario:
+            Given I have 3 "red \"delicious\" apples" at 0.5
+    `)
+
+    AssertThat(t, count, Equals(3))
+    AssertThat(t, what, Equals(`red "delicious" apples`))
+    AssertThat(t, price, Equals(0.5))
+}
+
+func TestRegisterExpressionForcesExpressionSyntax(t *testing.T) {
+    g := createWriterlessRunner()
+    calls := 0
+    g.RegisterExpression("I eat a cucumber(s)", func(w *World) { calls++ })
+    g.Execute(`Feature:
+        Scenario:
+            Given I eat a cucumber
+            And I eat a cucumbers
+    `)
+
+    AssertThat(t, calls, Equals(2))
+}
+
+type color string
+
+func parseColor(s string) (color, error) {
+    if s == "blue" {
+        return "", fmt.Errorf("blue is not allowed")
+    }
+    return color(strings.ToUpper(s)), nil
+}
+
+func TestDefinedParameterTypesCanBeUsedInExpressions(t *testing.T) {
+    g := createWriterlessRunner()
+    AssertThat(t, g.DefineParameterType("color", "red|green|blue", parseColor) == nil, IsTrue)
+    var got color
+    g.RegisterStepDef("I paint it {color}", func(c color) { got = c })
+    g.Execute(`Feature:
+        Scenario:
+            Given I paint it green
+    `)
+
+    AssertThat(t, got, Equals(color("GREEN")))
+}
+
+func TestDefinedParameterTypesConvertRegexpCaptures(t *testing.T) {
+    g := createWriterlessRunner()
+    g.DefineParameterType("color", "red|green|blue", parseColor)
+    var got color
+    g.RegisterStepDef(`^I paint it (\w+)$`, func(w *World, c color) { got = c })
+    g.Execute(`Feature:
+        Scenario:
+            Given I paint it red
+    `)
+
+    AssertThat(t, got, Equals(color("RED")))
+}
+
+func TestDefinedParameterTypesLeaveBuiltinTypesAlone(t *testing.T) {
+    g := createWriterlessRunner()
+    AssertThat(t, g.DefineParameterType("upper", "[A-Z]+", func(s string) string { return s + "!" }) == nil, IsTrue)
+    AssertThat(t, g.DefineParameterType("lower", "[a-z]+", func(s string) string { return s + "?" }) == nil, IsTrue)
+    AssertThat(t, g.DefineParameterType("count", `\d+`, func(s string) (int, error) { return 42, nil }) == nil, IsTrue)
+    var name string
+    var n int
+    g.RegisterStepDef(`^I am (\w+)$`, func(s string) { name = s })
+    g.RegisterStepDef(`^I have (\d+) apples$`, func(i int) { n = i })
+    g.Execute(`Feature:
+        Scenario:
+            Given I am Bob
+            And I have 3 apples
+    `)
+
+    AssertThat(t, len(g.registrationErrors), Equals(0))
+    AssertThat(t, name, Equals("Bob"))
+    AssertThat(t, n, Equals(3))
+}
+
+func TestTransformerErrorsFailTheStep(t *testing.T) {
+    g := createWriterlessRunner()
+    g.DefineParameterType("color", "red|green|blue", parseColor)
+    stp := StepFromString("I paint it blue")
+    g.RegisterStepDef("I paint it {color}", func(c color) { })
+    g.steps[0].execute(&stp, nil, &stp.errors)
+
+    AssertThat(t, stp.hasErrors, IsTrue)
+    AssertThat(t, strings.Contains(stp.errors.String(), `cannot transform "blue" to {color}: blue is not allowed`), IsTrue)
+}
+
+func TestDefineParameterTypeRejectsBadDefinitions(t *testing.T) {
+    g := createWriterlessRunner()
+
+    AssertThat(t, g.DefineParameterType("int", `\d+`, strconv.Atoi) != nil, IsTrue)
+    AssertThat(t, g.DefineParameterType("color", `(red|green)`, parseColor) != nil, IsTrue)
+    AssertThat(t, g.DefineParameterType("color", `red(`, parseColor) != nil, IsTrue)
+    AssertThat(t, g.DefineParameterType("color", `red`, func(s string) (color, string) { return "", "" }) != nil, IsTrue)
+    AssertThat(t, g.DefineParameterType("my color", `red`, parseColor) != nil, IsTrue)
+}
+
+func TestParameterTypesArePerRunner(t *testing.T) {
+    g := createWriterlessRunner()
+    g.DefineParameterType("color", "red|green|blue", parseColor)
+
+    AssertThat(t, createWriterlessRunner().DefineParameterType("color", "red|green|blue", parseColor) == nil, IsTrue)
+    AssertThat(t, looksLikeExpression("I paint it {color}", builtinParameterTypes), IsFalse)
+}
@@ -1,12 +1,23 @@
 // Support the Gherkin language, as found in Ruby's Cucumber and Python's Lettuce projects.
 package gherkin
 
-import "io"
-import matchers "github.com/tychofreeman/go-matchers"
+import (
+    "context"
+    "flag"
+    "io"
+    "os"
+    "strconv"
+    matchers "github.com/tychofreeman/go-matchers"
+)
 
 // Static Runner object to make creating tests easier
 var DefaultRunner = CreateRunner()
 
+var tagsFlag = flag.String("gherkin.tags", "", `only run scenarios matching this tag expression, e.g. "@smoke and not @slow"`)
+var snippetsFlag = flag.String("gherkin.snippets", "", "also write snippets for undefined steps to this file")
+var strictFlag = flag.Bool("gherkin.strict", false, "fail on undefined and pending steps, as well as failed and ambiguous ones")
+var dryRunFlag = flag.Bool("gherkin.dry-run", false, "match every step against the step definitions without running anything")
+
 // Use this function to let the user know that this
 // test is not complete.
 func Pending() {
@@ -23,24 +34,97 @@ func TearDown(teardown func()) {
     DefaultRunner.SetTearDownFn(teardown)
 }
 
+// Pass-through for Runner.BeforeSuite()
+func BeforeSuite(f func()) {
+    DefaultRunner.BeforeSuite(f)
+}
+
+// Pass-through for Runner.AfterSuite()
+func AfterSuite(f func()) {
+    DefaultRunner.AfterSuite(f)
+}
+
+// Pass-through for Runner.BeforeFeature()
+func BeforeFeature(f func(*Feature)) {
+    DefaultRunner.BeforeFeature(f)
+}
+
+// Pass-through for Runner.AfterFeature()
+func AfterFeature(f func(*Feature)) {
+    DefaultRunner.AfterFeature(f)
+}
+
+// Pass-through for Runner.BeforeScenario()
+func BeforeScenario(f func(*ScenarioInfo)) {
+    DefaultRunner.BeforeScenario(f)
+}
+
+// Pass-through for Runner.AfterScenario()
+func AfterScenario(f func(*ScenarioInfo)) {
+    DefaultRunner.AfterScenario(f)
+}
+
+// Pass-through for Runner.Before()
+func Before(tagExpr string, f func(*ScenarioInfo)) {
+    DefaultRunner.Before(tagExpr, f)
+}
+
+// Pass-through for Runner.After()
+func After(tagExpr string, f func(*ScenarioInfo)) {
+    DefaultRunner.After(tagExpr, f)
+}
+
+// Pass-through for Runner.Around()
+func Around(tagExpr string, f func(s *ScenarioInfo, run func())) {
+    DefaultRunner.Around(tagExpr, f)
+}
+
+// Pass-through for Runner.BeforeStep()
+func BeforeStep(f func(*StepInfo)) {
+    DefaultRunner.BeforeStep(f)
+}
+
+// Pass-through for Runner.AfterStep()
+func AfterStep(f func(*StepInfo)) {
+    DefaultRunner.AfterStep(f)
+}
+
+// Pass-through for Runner.SetWorldFactory()
+func SetWorldFactory(factory func() interface{}) {
+    DefaultRunner.SetWorldFactory(factory)
+}
+
 // Pass-through for Runner.RegisterStepDef()
-func RegisterStepDef(pattern string, stepdef func(*World)) {
+func RegisterStepDef(pattern string, stepdef interface{}) {
     DefaultRunner.RegisterStepDef(pattern, stepdef)
 }
 
-func Given(pattern string, stepdef func(*World)) {
-    DefaultRunner.RegisterStepDef(pattern, stepdef)
+// Pass-through for Runner.RegisterExpression()
+func Expr(expr string, stepdef interface{}) {
+    DefaultRunner.RegisterExpression(expr, stepdef)
 }
 
-func When(pattern string, stepdef func(*World)) {
-    DefaultRunner.RegisterStepDef(pattern, stepdef)
+// Pass-through for Runner.DefineParameterType()
+func DefineParameterType(name, regexp string, transformer interface{}) error {
+    return DefaultRunner.DefineParameterType(name, regexp, transformer)
 }
 
-func Then(pattern string, stepdef func(*World)) {
-    DefaultRunner.RegisterStepDef(pattern, stepdef)
+// Pass-through for Runner.Given()
+func Given(pattern string, stepdef interface{}) {
+    DefaultRunner.Given(pattern, stepdef)
+}
+
+// Pass-through for Runner.When()
+func When(pattern string, stepdef interface{}) {
+    DefaultRunner.When(pattern, stepdef)
 }
 
-func And(pattern string, stepdef func(*World)) {
+// Pass-through for Runner.Then()
+func Then(pattern string, stepdef interface{}) {
+    DefaultRunner.Then(pattern, stepdef)
+}
+
+func And(pattern string, stepdef interface{}) {
     DefaultRunner.RegisterStepDef(pattern, stepdef)
 }
 
@@ -49,8 +133,92 @@ func SetOutput(output io.Writer) {
     DefaultRunner.SetOutput(output)
 }
 
+// Pass-through for Runner.SetColor()
+func SetColor(color bool) {
+    DefaultRunner.SetColor(color)
+}
+
+// Pass-through for Runner.SetDefaultLanguage()
+func SetDefaultLanguage(language string) error {
+    return DefaultRunner.SetDefaultLanguage(language)
+}
+
+// Pass-through for Runner.AllowAmbiguousFirstMatch()
+func AllowAmbiguousFirstMatch() {
+    DefaultRunner.AllowAmbiguousFirstMatch()
+}
+
+// Pass-through for Runner.SetStrictKeywords()
+func SetStrictKeywords(strict bool) {
+    DefaultRunner.SetStrictKeywords(strict)
+}
+
+// Pass-through for Runner.SetStrict()
+func SetStrict(strict bool) {
+    DefaultRunner.SetStrict(strict)
+}
+
+// Pass-through for Runner.SetDryRun()
+func SetDryRun(dryRun bool) {
+    DefaultRunner.SetDryRun(dryRun)
+}
+
+// Pass-through for Runner.SetTagFilter()
+func SetTagFilter(expr string) error {
+    return DefaultRunner.SetTagFilter(expr)
+}
+
+// Pass-through for Runner.SetSnippetFile()
+func SetSnippetFile(path string) {
+    DefaultRunner.SetSnippetFile(path)
+}
+
+// Pass-through for Runner.Context()
+func Context() context.Context {
+    return DefaultRunner.Context()
+}
+
 // Pass-through for Runner.Run()
 // This should be called after everything else.
+//
+// The -gherkin.tags flag, or failing that the GHERKIN_TAGS environment
+// variable, overrides any tag filter set on DefaultRunner. Likewise the
+// -gherkin.snippets flag overrides any snippet file, and -gherkin.dry-run
+// turns on a dry run. The GHERKIN_STRICT environment variable, if set to
+// true or false, overrides strict mode, and the -gherkin.strict flag
+// turns it on.
 func Run(t matchers.Errorable) {
-    DefaultRunner.Run(t)
+    RunContext(context.Background(), t)
+}
+
+// Pass-through for Runner.RunContext(), with the same flags as Run().
+func RunContext(ctx context.Context, t matchers.Errorable) {
+    if len(*snippetsFlag) > 0 {
+        DefaultRunner.SetSnippetFile(*snippetsFlag)
+    }
+    if *dryRunFlag {
+        DefaultRunner.SetDryRun(true)
+    }
+    if env := os.Getenv("GHERKIN_STRICT"); len(env) > 0 {
+        strict, err := strconv.ParseBool(env)
+        if err != nil {
+            t.Errorf("GHERKIN_STRICT must be true or false, not %q", env)
+            return
+        }
+        DefaultRunner.SetStrict(strict)
+    }
+    if *strictFlag {
+        DefaultRunner.SetStrict(true)
+    }
+    tags := *tagsFlag
+    if len(tags) == 0 {
+        tags = os.Getenv("GHERKIN_TAGS")
+    }
+    if len(tags) > 0 {
+        if err := DefaultRunner.SetTagFilter(tags); err != nil {
+            t.Errorf("%v", err)
+            return
+        }
+    }
+    DefaultRunner.RunContext(ctx, t)
 }
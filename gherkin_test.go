@@ -1,6 +1,9 @@
 package gherkin
 
 import (
+    "bytes"
+    "fmt"
+    "strings"
     "testing"
     . "github.com/tychofreeman/go-matchers"
 )
@@ -20,7 +23,7 @@ var featureText = `Feature: My Feature
         * the third setup
         When     the third action has leading spaces
         When the third action has trailing spaces               
-    This is ignored`
+    # This is ignored`
 
 func assertMatchCalledOrNot(t *testing.T, step string, pattern string, isCalled bool) {
     wasCalled := false
@@ -51,18 +54,58 @@ func TestAvoidsNonMatchingMethod(t *testing.T) {
     matchingFunctionIsNotCalled(t, featureText, "^A")
 }
 
-func TestCallsOnlyFirstMatchingMethod(t *testing.T) {
-    wasCalled := false
-    first := func(w *World) { }
+func TestCallsOnlyFirstMatchingMethodWhenAmbiguityIsAllowed(t *testing.T) {
+    firstWasCalled, secondWasCalled := false, false
+    first := func(w *World) {
+        firstWasCalled = true
+    }
     second := func(w *World) {
-        wasCalled = true
+        secondWasCalled = true
     }
 
     g := createWriterlessRunner()
+    g.AllowAmbiguousFirstMatch()
     g.RegisterStepDef(".", first)
     g.RegisterStepDef(".", second)
-    g.Execute("Given only the first step is called")
-    AssertThat(t, wasCalled, Equals(false))
+    g.Execute(`Feature:
+        Scenario:
+            Given only the first step is called
+    `)
+    AssertThat(t, firstWasCalled, IsTrue)
+    AssertThat(t, secondWasCalled, IsFalse)
+}
+
+func TestAmbiguousStepCallsNoMatchingMethod(t *testing.T) {
+    calls := 0
+    g := createWriterlessRunner()
+    g.RegisterStepDef(".", func(w *World) { calls++ })
+    g.RegisterStepDef("^only", func(w *World) { calls++ })
+    rpt := g.Execute(`Feature:
+        Scenario:
+            Given only the first step is called
+            Then it does not matter
+    `)
+
+    AssertThat(t, calls, Equals(0))
+    AssertThat(t, rpt.ambiguousSteps, Equals(1))
+    AssertThat(t, rpt.skippedSteps, Equals(1))
+}
+
+func TestAmbiguousStepListsEachMatchingPatternAndWhereItWasRegistered(t *testing.T) {
+    var buf bytes.Buffer
+    g := createWriterlessRunner()
+    g.SetOutput(&buf)
+    g.RegisterStepDef("^I have (\\d+) apples$", func(w *World) { })
+    g.RegisterStepDef("I have {int} apples", func(n int) { })
+    g.Execute(`Feature:
+        Scenario:
+            Given I have 3 apples
+    `)
+
+    out := buf.String()
+    AssertThat(t, strings.Contains(out, "AMBIGUOUS -     Given I have 3 apples"), IsTrue)
+    AssertThat(t, strings.Contains(out, "^I have (\\d+) apples$ (gherkin_test.go:"), IsTrue)
+    AssertThat(t, strings.Contains(out, "I have {int} apples (gherkin_test.go:"), IsTrue)
 }
 
 func TestRemovesGivenFromMatchLine(t *testing.T) {
@@ -250,6 +293,110 @@ func TestSupportsMultipleMultiLineStepsPerScenario(t *testing.T) {
     AssertThat(t, whenData, Equals(expectedWhenData))
 }
 
+func TestPassesDocStringToStep(t *testing.T) {
+    g := createWriterlessRunner()
+    var content, mediaType string
+    g.RegisterStepDef(".", func(w *World) { content, mediaType = w.DocString() })
+    g.Execute(`Feature:
+        Scenario:
+            Given a document
+                """json
+                {"name": "Bob"}
+                """
+    `)
+
+    AssertThat(t, content, Equals(`{"name": "Bob"}`))
+    AssertThat(t, mediaType, Equals("json"))
+}
+
+func TestScenarioTagsIncludeFeatureTags(t *testing.T) {
+    g := createWriterlessRunner()
+    var tags []string
+    g.RegisterStepDef(".", func(w *World) { tags = w.Scenario().Tags })
+    g.Execute(`@billing @fast
+    Feature:
+        @fast @db
+        Scenario:
+            Given .
+    `)
+
+    AssertThat(t, tags, Equals([]string{"@billing", "@fast", "@db"}))
+}
+
+func TestGeneratedScenariosInheritExamplesTags(t *testing.T) {
+    g := createWriterlessRunner()
+    hasTags := []bool{}
+    g.RegisterStepDef(".", func(w *World) {
+        hasTags = append(hasTags, w.Scenario().HasTag("@outline") && w.Scenario().HasTag("@second"))
+    })
+    g.Execute(`Feature:
+        @outline
+        Scenario Outline:
+            Given <x>
+        @first
+        Examples:
+            |x|
+            |a|
+        @second
+        Examples:
+            |x|
+            |b|
+    `)
+
+    AssertThat(t, hasTags, Equals([]bool{false, true}))
+}
+
+func TestBackgroundStepsSeeTheScenarioBeingRun(t *testing.T) {
+    g := createWriterlessRunner()
+    names := []string{}
+    g.RegisterStepDef("^background$", func(w *World) { names = append(names, w.Scenario().Name) })
+    g.Execute(`Feature:
+        Background:
+            Given background
+        Scenario: one
+            Then this
+        Scenario: two
+            Then this
+    `)
+
+    AssertThat(t, names, Equals([]string{"one", "two"}))
+}
+
+func TestRuleBackgroundRunsAfterFeatureBackground(t *testing.T) {
+    g := createWriterlessRunner()
+    calls := []string{}
+    g.RegisterStepDef("^(.*)$", func(w *World) { calls = append(calls, w.GetRegexParam()) })
+    g.Execute(`Feature:
+        Background:
+            Given feature background
+        Scenario:
+            Then outside
+        Rule:
+            Background:
+                Given rule background
+            Scenario:
+                Then inside
+    `)
+
+    AssertThat(t, calls, Equals([]string{"feature background", "outside", "feature background", "rule background", "inside"}))
+}
+
+func TestScenariosInheritRuleTags(t *testing.T) {
+    g := createWriterlessRunner()
+    var info *ScenarioInfo
+    g.RegisterStepDef(".", func(w *World) { info = w.Scenario() })
+    g.Execute(`@feature
+    Feature:
+        @rule
+        Rule: billing
+            Scenario:
+                Given .
+    `)
+
+    AssertThat(t, info.Rule, Equals("billing"))
+    AssertThat(t, info.Tags, Equals([]string{"@feature", "@rule"}))
+}
+
 func TestAllowsAccessToFirstRegexCapture(t *testing.T) {
     g := createWriterlessRunner()
     captured := ""
@@ -263,22 +410,70 @@ func TestAllowsAccessToFirstRegexCapture(t *testing.T) {
 }
 
 func TestFailsGracefullyWithOutOfBoundsRegexCaptures(t *testing.T) {
+    var buf bytes.Buffer
     g := createWriterlessRunner()
+    g.SetOutput(&buf)
     g.RegisterStepDef(".", func(w *World) { w.GetRegexParam() })
 
-    defer func() {
-        r := recover()
-        AssertThat(t, r, Equals("GetRegexParam() called too many times."))
-    }()
-
-    g.Execute(`Feature:
+    rpt := g.Execute(`Feature:
         Scenario:
             Given .
     `)
 
+    AssertThat(t, rpt.failedSteps, Equals(1))
+    AssertThat(t, strings.Contains(buf.String(), "panic: GetRegexParam() called too many times."), IsTrue)
 }
 
-func DISABLED_TestOnlyExecutesStepsBelowScenarioLine(t *testing.T) {
+func TestPanickingStepFailsAndSkipsTheRestOfTheScenario(t *testing.T) {
+    var buf bytes.Buffer
+    calls := 0
+    g := createWriterlessRunner()
+    g.SetOutput(&buf)
+    g.RegisterStepDef("^it blows up$", func(w *World) {
+        var m map[string]int
+        m["boom"]++
+    })
+    g.RegisterStepDef("^it works$", func(w *World) { calls++ })
+    rpt := g.Execute(`Feature:
+        Scenario:
+            Given it blows up
+            Then it works
+        Scenario:
+            Then it works
+    `)
+
+    AssertThat(t, calls, Equals(1))
+    AssertThat(t, rpt.failedSteps, Equals(1))
+    AssertThat(t, rpt.skippedSteps, Equals(1))
+    AssertThat(t, rpt.passedSteps, Equals(1))
+    AssertThat(t, strings.Contains(buf.String(), "panic: assignment to entry in nil map"), IsTrue)
+    AssertThat(t, strings.Contains(buf.String(), "gherkin_test.go:"), IsTrue)
+}
+
+func TestStepReturningAnErrorFails(t *testing.T) {
+    var buf bytes.Buffer
+    g := createWriterlessRunner()
+    g.SetOutput(&buf)
+    g.RegisterStepDef(`^I have (\d+) apples$`, func(n int) error {
+        if n > 2 {
+            return fmt.Errorf("%d apples is too many", n)
+        }
+        return nil
+    })
+    rpt := g.Execute(`Feature:
+        Scenario:
+            Given I have 2 apples
+            Given I have 3 apples
+            Given I have 1 apples
+    `)
+
+    AssertThat(t, rpt.passedSteps, Equals(1))
+    AssertThat(t, rpt.failedSteps, Equals(1))
+    AssertThat(t, rpt.skippedSteps, Equals(1))
+    AssertThat(t, strings.Contains(buf.String(), "3 apples is too many"), IsTrue)
+}
+
+func TestOnlyExecutesStepsBelowScenarioLine(t *testing.T) {
     g := createWriterlessRunner()
     wasRun := false
     g.RegisterStepDef(".", func(w *World) { wasRun = true })
@@ -301,7 +496,7 @@ func TestScenarioOutlineWithoutExampleDoesNotExecute(t *testing.T) {
 }
 
 func TestScenarioOutlineReplacesFieldWithValueInExample(t *testing.T) {
-    so := ScenarioOutline()
+    so := scenario_outline{}
     so.AddStep(StepFromString(`<count> pops`))
     scenario := so.CreateForExample(map[string]string{"count":"5"})
 
@@ -309,7 +504,7 @@ func TestScenarioOutlineReplacesFieldWithValueInExample(t *testing.T) {
 }
 
 func TestScenarioOutlineReplacesManyFieldsWithValuesInExample(t *testing.T) {
-    so := ScenarioOutline()
+    so := scenario_outline{}
     so.AddStep(StepFromString(`<count> <name>`))
     scenario := so.CreateForExample(map[string]string{"count":"5", "name":"pops"})
 
@@ -317,7 +512,7 @@ func TestScenarioOutlineReplacesManyFieldsWithValuesInExample(t *testing.T) {
 }
 
 func TestScenarioOutlineSupportsMultipleLines(t *testing.T) {
-    so := ScenarioOutline()
+    so := scenario_outline{}
     so.AddStep(StepFromString(`<count> <name>`))
     so.AddStep(StepFromString(`<name> <type>`))
     scenario := so.CreateForExample(map[string]string{"count":"5", "name":"pops", "type":"music"})
@@ -326,6 +521,43 @@ func TestScenarioOutlineSupportsMultipleLines(t *testing.T) {
     AssertThat(t, scenario.steps[1].line, Equals(`pops music`))
 }
 
+func TestScenarioOutlineKeepsOriginalLine(t *testing.T) {
+    so := scenario_outline{}
+    so.AddStep(StepFromStringAndOrig(`<count> pops`, `    Given <count> pops`))
+    scenario := so.CreateForExample(map[string]string{"count":"5"})
+
+    AssertThat(t, scenario.steps[0].orig, Equals(`    Given 5 pops`))
+}
+
+func TestScenarioOutlineReplacesFieldsInTablesAndDocStrings(t *testing.T) {
+    g := createWriterlessRunner()
+    var data []map[string]string
+    var doc string
+    var name string
+    g.RegisterStepDef("^people$", func(w *World) { data = w.MultiStep })
+    g.RegisterStepDef("^a document$", func(w *World) {
+        doc, _ = w.DocString()
+        name = w.Scenario().Name
+    })
+    g.Execute(`Feature:
+        Scenario Outline: greeting <who>
+            Given people
+                |<key>|
+                |<who>|
+            And a document
+                """
+                Hello <who>
+                """
+        Examples:
+            |key |who|
+            |name|Bob|
+    `)
+
+    AssertThat(t, data, Equals([]map[string]string{map[string]string{"name":"Bob"}}))
+    AssertThat(t, doc, Equals("Hello Bob"))
+    AssertThat(t, name, Equals("greeting Bob (key: name, who: Bob)"))
+}
+
 func TestExecutesScenarioOncePerLineInExample(t *testing.T) {
     g := createWriterlessRunner()
     timesRun := 0
@@ -342,6 +574,112 @@ func TestExecutesScenarioOncePerLineInExample(t *testing.T) {
     AssertThat(t, timesRun, Equals(2))
 }
 
+func TestEachExamplesBlockHasItsOwnHeader(t *testing.T) {
+    g := createWriterlessRunner()
+    lines := []string{}
+    names := []string{}
+    g.RegisterStepDef("^(.*)$", func(w *World) {
+        lines = append(lines, w.GetRegexParam())
+        names = append(names, w.Scenario().Name)
+    })
+    g.Execute(`Feature:
+        Scenario Outline: eating
+            Given <count> <fruit>
+        Examples: first
+            |count|fruit|
+            |1|apple|
+        Examples: second
+            Described here.
+            |fruit|count|
+            |pear|2|
+    `)
+
+    AssertThat(t, lines, Equals([]string{"1 apple", "2 pear"}))
+    AssertThat(t, names, Equals([]string{"eating (count: 1, fruit: apple)", "eating (fruit: pear, count: 2)"}))
+}
+
+func TestExamplesCanBeFilteredByTag(t *testing.T) {
+    g := createWriterlessRunner()
+    g.SetTagFilter("@second")
+    lines := []string{}
+    g.RegisterStepDef("^(.*)$", func(w *World) { lines = append(lines, w.GetRegexParam()) })
+    g.Execute(`Feature:
+        Scenario Outline:
+            Given <count> <fruit>
+        @first
+        Examples:
+            |count|fruit|
+            |1|apple|
+        @second
+        Examples:
+            |fruit|count|
+            |pear|2|
+    `)
+
+    AssertThat(t, lines, Equals([]string{"2 pear"}))
+}
+
+func TestEachExampleRowIsAScenarioWithSetUpAndBackground(t *testing.T) {
+    g := createWriterlessRunner()
+    calls := []string{}
+    g.SetSetUpFn(func() { calls = append(calls, "setup") })
+    g.SetTearDownFn(func() { calls = append(calls, "teardown") })
+    g.RegisterStepDef("^(.*)$", func(w *World) { calls = append(calls, w.GetRegexParam()) })
+    rpt := g.Execute(`Feature:
+        Background:
+            Given background
+        Scenario Outline:
+            Given <x>
+        Examples:
+            |x|
+            |a|
+            |b|
+    `)
+
+    AssertThat(t, calls, Equals([]string{"setup", "background", "a", "teardown", "setup", "background", "b", "teardown"}))
+    AssertThat(t, rpt.scenarioCount, Equals(2))
+}
+
+func TestExampleRowsArePrintedWithTheirResult(t *testing.T) {
+    g := createWriterlessRunner()
+    out := &bytes.Buffer{}
+    g.SetOutput(out)
+    g.SetColor(true)
+    g.RegisterStepDef("^good$", func(w *World) { })
+    g.RegisterStepDef("^bad$", func(w *World) { w.Errorf("oops") })
+    g.Execute(`Feature:
+        Scenario Outline: checking <x>
+            Given <x>
+        Examples:
+            |x|
+            |good|
+            |bad|
+    `)
+
+    AssertThat(t, strings.Contains(out.String(), "  Scenario Outline: checking <x>\n    Given <x>\n"), IsTrue)
+    AssertThat(t, strings.Contains(out.String(), colorPassed + "      | good |" + colorReset), IsTrue)
+    AssertThat(t, strings.Contains(out.String(), colorFailed + "      | bad |" + colorReset + "\n  Scenario Outline: checking bad"), IsTrue)
+}
+
+func TestExampleRowsAreNotColouredUnlessTheOutputIsATerminal(t *testing.T) {
+    g := createWriterlessRunner()
+    out := &bytes.Buffer{}
+    g.SetOutput(out)
+    g.RegisterStepDef("^good$", func(w *World) { })
+    g.RegisterStepDef("^bad$", func(w *World) { w.Errorf("oops") })
+    g.Execute(`Feature:
+        Scenario Outline: checking <x>
+            Given <x>
+        Examples:
+            |x|
+            |good|
+            |bad|
+    `)
+
+    AssertThat(t, strings.Contains(out.String(), "\033["), IsFalse)
+    AssertThat(t, strings.Contains(out.String(), "\n      | good |\n      | bad |\n  Scenario Outline: checking bad"), IsTrue)
+}
+
 func TestBackgroundDoesntExecuteBackgroundWhenRun(t *testing.T) {
     g := createWriterlessRunner()
     wasRun := false
@@ -354,6 +692,160 @@ func TestBackgroundDoesntExecuteBackgroundWhenRun(t *testing.T) {
    AssertThat(t, wasRun, IsFalse)
 }
 
-// Support PyStrings?
-// Support tags?
 // Support reporting.
+
+func TestStrictKeywordsMatchOnlyStepsOfTheSameType(t *testing.T) {
+    calls := []string{}
+    g := createWriterlessRunner()
+    g.SetStrictKeywords(true)
+    g.Given("^the light is (red|green)$", func(w *World, c string) { calls = append(calls, "given " + c) })
+    g.Then("^the light is (red|green)$", func(w *World, c string) { calls = append(calls, "then " + c) })
+    g.When("^I wait$", func(w *World) { calls = append(calls, "when") })
+    g.Execute(`Feature:
+        Scenario:
+            Given the light is red
+            And the light is green
+            When I wait
+            Then the light is green
+            But the light is red
+    `)
+
+    AssertThat(t, calls, Equals([]string{"given red", "given green", "when", "then green", "then red"}))
+}
+
+func TestStrictKeywordsLeaveStepsOfOtherTypesUndefined(t *testing.T) {
+    g := createWriterlessRunner()
+    g.SetStrictKeywords(true)
+    g.Then("^it works$", func(w *World) { })
+    g.RegisterStepDef("^anything$", func(w *World) { })
+    rpt := g.Execute(`Feature:
+        Scenario:
+            Given anything
+            When it works
+    `)
+
+    AssertThat(t, rpt.passedSteps, Equals(1))
+    AssertThat(t, rpt.undefinedSteps, Equals(1))
+}
+
+func TestKeywordsAreIgnoredUnlessStrict(t *testing.T) {
+    calls := 0
+    g := createWriterlessRunner()
+    g.Then("^it works$", func(w *World) { calls++ })
+    g.Execute(`Feature:
+        Scenario:
+            Given it works
+            When it works
+    `)
+
+    AssertThat(t, calls, Equals(2))
+}
+
+func TestConjunctionsInheritTheKeywordTypeInOtherLanguages(t *testing.T) {
+    calls := []string{}
+    g := createWriterlessRunner()
+    g.SetStrictKeywords(true)
+    g.When("^ich warte$", func(w *World) { calls = append(calls, "when") })
+    g.Then("^ich warte$", func(w *World) { calls = append(calls, "then") })
+    g.Execute(`# language: de
+    Funktionalität: Warten
+        Szenario: warten
+            Wenn ich warte
+            Und ich warte
+            Dann ich warte
+            Und ich warte
+    `)
+
+    AssertThat(t, calls, Equals([]string{"when", "when", "then", "then"}))
+}
+
+type basket struct {
+    apples int
+}
+
+func TestWorldFactoryMakesStateForEachScenario(t *testing.T) {
+    made := 0
+    seen := []int{}
+    g := createWriterlessRunner()
+    g.SetWorldFactory(func() interface{} {
+        made++
+        return &basket{}
+    })
+    g.RegisterStepDef("^I add (\\d+) apples?$", func(w *World, n int) { w.State().(*basket).apples += n })
+    g.RegisterStepDef("^I count them$", func(w *World) { seen = append(seen, w.State().(*basket).apples) })
+    g.Execute(`Feature:
+        Background:
+            Given I add 1 apple
+        Scenario: one
+            When I add 2 apples
+            Then I count them
+        Scenario Outline: many
+            When I add <n> apples
+            Then I count them
+            Examples:
+                | n |
+                | 3 |
+                | 4 |
+    `)
+
+    AssertThat(t, made, Equals(3))
+    AssertThat(t, seen, Equals([]int{3, 4, 5}))
+}
+
+func TestWorldValuesLastForOneScenario(t *testing.T) {
+    seen := []interface{}{}
+    g := createWriterlessRunner()
+    g.RegisterStepDef("^I remember (\\w+)$", func(w *World, s string) { w.Set("thing", s) })
+    g.RegisterStepDef("^I recall it$", func(w *World) { seen = append(seen, w.Get("thing")) })
+    g.Execute(`Feature:
+        Background:
+            Given I recall it
+        Scenario:
+            Given I remember apples
+            Then I recall it
+        Scenario:
+            Then I recall it
+    `)
+
+    AssertThat(t, seen, Equals([]interface{}{nil, "apples", nil, nil}))
+}
+
+func TestDryRunMatchesStepsWithoutCallingAnything(t *testing.T) {
+    calls := []string{}
+    g := createWriterlessRunner()
+    g.SetDryRun(true)
+    g.SetSetUpFn(func() { calls = append(calls, "setUp") })
+    g.SetTearDownFn(func() { calls = append(calls, "tearDown") })
+    g.SetWorldFactory(func() interface{} { calls = append(calls, "world"); return nil })
+    g.BeforeFeature(func(f *Feature) { calls = append(calls, "beforeFeature") })
+    g.BeforeScenario(func(s *ScenarioInfo) { calls = append(calls, "beforeScenario") })
+    g.AfterStep(func(s *StepInfo) { calls = append(calls, "afterStep") })
+    g.RegisterStepDef("^it (works|is ambiguous)$", func(w *World, s string) { calls = append(calls, "step") })
+    g.RegisterStepDef("^it is (ambiguous)$", func(w *World, s string) { calls = append(calls, "step") })
+    g.RegisterStepDef("^it is pending$", func(w *World) { Pending() })
+    rpt := g.Execute(`Feature:
+        Scenario:
+            Given it is pending
+            When it is undefined with 3 apples
+            Then it is ambiguous
+            And it is pending
+    `)
+
+    AssertThat(t, calls, Equals([]string{}))
+    AssertThat(t, rpt.skippedSteps, Equals(2))
+    AssertThat(t, rpt.undefinedSteps, Equals(1))
+    AssertThat(t, rpt.ambiguousSteps, Equals(1))
+    AssertThat(t, rpt.pendingSteps, Equals(0))
+    AssertThat(t, len(g.snippets.list), Equals(1))
+}
+
+func TestDryRunSkipsSuiteHooks(t *testing.T) {
+    wasCalled := false
+    g := createWriterlessRunner()
+    g.SetDryRun(true)
+    g.BeforeSuite(func() { wasCalled = true })
+    g.AfterSuite(func() { wasCalled = true })
+    g.Run(&errorCollector{})
+
+    AssertThat(t, wasCalled, IsFalse)
+}
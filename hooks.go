@@ -0,0 +1,260 @@
+package gherkin
+
+import (
+    "fmt"
+    "io"
+)
+
+// The functions registered to run around the suite, and each feature,
+// scenario and step. Before hooks run in the order they were registered
+// and After hooks in the reverse order.
+type hooks struct {
+    beforeSuite []func()
+    afterSuite []func()
+    beforeFeature []func(*Feature)
+    afterFeature []func(*Feature)
+    beforeScenario []scenarioHook
+    afterScenario []scenarioHook
+    aroundScenario []aroundHook
+    beforeStep []func(*StepInfo)
+    afterStep []func(*StepInfo)
+}
+
+// A scenario hook, which only runs for scenarios matching its tag
+// expression, if it has one.
+type scenarioHook struct {
+    filter tagExpression
+    f func(*ScenarioInfo)
+}
+
+type aroundHook struct {
+    filter tagExpression
+    f func(*ScenarioInfo, func())
+}
+
+func hookMatches(filter tagExpression, info *ScenarioInfo) bool {
+    if filter == nil {
+        return true
+    }
+    tags := []string{}
+    if info != nil {
+        tags = info.Tags
+    }
+    return filter.matches(tags)
+}
+
+// A hook fails by panicking. Unlike a step, a hook's failure is reported
+// as soon as it happens, and never stops the other hooks from running.
+func callHook(name string, f func(), output io.Writer) (err error) {
+    defer func() {
+        if rec := recover(); rec != nil {
+            err = fmt.Errorf("%s hook failed: %v", name, rec)
+            if output != nil {
+                fmt.Fprintf(output, "HOOK FAILED - %s: %v\n", name, rec)
+            }
+        }
+    }()
+    f()
+    return nil
+}
+
+func (h *hooks) callBeforeSuite(output io.Writer) (failures int) {
+    for _, f := range h.beforeSuite {
+        if callHook("BeforeSuite", f, output) != nil {
+            failures++
+        }
+    }
+    return
+}
+
+func (h *hooks) callAfterSuite(output io.Writer) (failures int) {
+    for i := len(h.afterSuite) - 1; i >= 0; i-- {
+        if callHook("AfterSuite", h.afterSuite[i], output) != nil {
+            failures++
+        }
+    }
+    return
+}
+
+func (h *hooks) callBeforeFeature(f *Feature, output io.Writer) (failures int) {
+    for _, hook := range h.beforeFeature {
+        hook := hook
+        if callHook("BeforeFeature", func() { hook(f) }, output) != nil {
+            failures++
+        }
+    }
+    return
+}
+
+func (h *hooks) callAfterFeature(f *Feature, output io.Writer) (failures int) {
+    for i := len(h.afterFeature) - 1; i >= 0; i-- {
+        hook := h.afterFeature[i]
+        if callHook("AfterFeature", func() { hook(f) }, output) != nil {
+            failures++
+        }
+    }
+    return
+}
+
+// Scenario hooks return their errors, so that they can be attached to
+// the scenario's result.
+func (h *hooks) callBeforeScenario(info *ScenarioInfo, output io.Writer) (errs []error) {
+    for _, hook := range h.beforeScenario {
+        hook := hook
+        if !hookMatches(hook.filter, info) {
+            continue
+        }
+        if err := callHook("BeforeScenario", func() { hook.f(info) }, output); err != nil {
+            errs = append(errs, err)
+        }
+    }
+    return
+}
+
+func (h *hooks) callAfterScenario(info *ScenarioInfo, output io.Writer) (errs []error) {
+    for i := len(h.afterScenario) - 1; i >= 0; i-- {
+        hook := h.afterScenario[i]
+        if !hookMatches(hook.filter, info) {
+            continue
+        }
+        if err := callHook("AfterScenario", func() { hook.f(info) }, output); err != nil {
+            errs = append(errs, err)
+        }
+    }
+    return
+}
+
+// Calls run inside every matching Around hook, the first registered
+// outermost. If a hook does not call the function it is given, nothing
+// inside it is run.
+func (h *hooks) callAroundScenario(info *ScenarioInfo, run func(), output io.Writer) (errs []error) {
+    wrapped := run
+    for i := len(h.aroundScenario) - 1; i >= 0; i-- {
+        hook, next := h.aroundScenario[i], wrapped
+        if !hookMatches(hook.filter, info) {
+            continue
+        }
+        wrapped = func() {
+            if err := callHook("Around", func() { hook.f(info, next) }, output); err != nil {
+                errs = append(errs, err)
+            }
+        }
+    }
+    wrapped()
+    return
+}
+
+func (h *hooks) callBeforeStep(info *StepInfo, output io.Writer) (failures int) {
+    for _, hook := range h.beforeStep {
+        hook := hook
+        if callHook("BeforeStep", func() { hook(info) }, output) != nil {
+            failures++
+        }
+    }
+    return
+}
+
+func (h *hooks) callAfterStep(info *StepInfo, output io.Writer) (failures int) {
+    for i := len(h.afterStep) - 1; i >= 0; i-- {
+        hook := h.afterStep[i]
+        if callHook("AfterStep", func() { hook(info) }, output) != nil {
+            failures++
+        }
+    }
+    return
+}
+
+// Register a function to be called once, before any feature is Run().
+func (r *Runner) BeforeSuite(f func()) {
+    r.hooks.beforeSuite = append(r.hooks.beforeSuite, f)
+}
+
+// Register a function to be called once, after every feature is Run().
+func (r *Runner) AfterSuite(f func()) {
+    r.hooks.afterSuite = append(r.hooks.afterSuite, f)
+}
+
+// Register a function to be called before the scenarios of each feature.
+func (r *Runner) BeforeFeature(f func(*Feature)) {
+    r.hooks.beforeFeature = append(r.hooks.beforeFeature, f)
+}
+
+// Register a function to be called after the scenarios of each feature.
+func (r *Runner) AfterFeature(f func(*Feature)) {
+    r.hooks.afterFeature = append(r.hooks.afterFeature, f)
+}
+
+// Register a function to be called at the beginning of each scenario,
+// after the set-up function and inside any Around hooks. If it fails,
+// the scenario's steps are skipped.
+func (r *Runner) BeforeScenario(f func(*ScenarioInfo)) {
+    r.hooks.beforeScenario = append(r.hooks.beforeScenario, scenarioHook{nil, f})
+}
+
+// Register a function to be called at the end of each scenario, before
+// the tear-down function and inside any Around hooks.
+func (r *Runner) AfterScenario(f func(*ScenarioInfo)) {
+    r.hooks.afterScenario = append(r.hooks.afterScenario, scenarioHook{nil, f})
+}
+
+// Like BeforeScenario(), but only for scenarios matching the tag
+// expression, for example
+//
+//     r.Before("@database and not @readonly", func(s *ScenarioInfo) {...})
+//
+// It runs in the same order as the BeforeScenario() hooks, by when it
+// was registered. An empty expression matches every scenario.
+func (r *Runner) Before(tagExpr string, f func(*ScenarioInfo)) {
+    if filter, ok := r.hookFilter(tagExpr); ok {
+        r.hooks.beforeScenario = append(r.hooks.beforeScenario, scenarioHook{filter, f})
+    }
+}
+
+// Like AfterScenario(), but only for scenarios matching the tag
+// expression. See Before().
+func (r *Runner) After(tagExpr string, f func(*ScenarioInfo)) {
+    if filter, ok := r.hookFilter(tagExpr); ok {
+        r.hooks.afterScenario = append(r.hooks.afterScenario, scenarioHook{filter, f})
+    }
+}
+
+// Register a function which wraps each scenario matching the tag
+// expression, and must call run to execute the scenario's Before hooks,
+// steps and After hooks:
+//
+//     r.Around("@transaction", func(s *ScenarioInfo, run func()) {
+//         tx := db.Begin()
+//         defer tx.Rollback()
+//         run()
+//     })
+//
+// Around hooks run inside the set-up and tear-down functions, the first
+// registered outermost. A scenario whose Around hook does not call run is
+// reported as skipped, as are its steps.
+func (r *Runner) Around(tagExpr string, f func(s *ScenarioInfo, run func())) {
+    if filter, ok := r.hookFilter(tagExpr); ok {
+        r.hooks.aroundScenario = append(r.hooks.aroundScenario, aroundHook{filter, f})
+    }
+}
+
+// An invalid tag expression is reported when the runner is Run(), like
+// an invalid step definition.
+func (r *Runner) hookFilter(tagExpr string) (tagExpression, bool) {
+    filter, err := parseTagExpression(tagExpr)
+    if err != nil {
+        r.registrationErrors = append(r.registrationErrors, &RegistrationError{"hook", tagExpr, callerLocation(), err})
+        return nil, false
+    }
+    return filter, true
+}
+
+// Register a function to be called before each step which is run.
+func (r *Runner) BeforeStep(f func(*StepInfo)) {
+    r.hooks.beforeStep = append(r.hooks.beforeStep, f)
+}
+
+// Register a function to be called after each step which is run, with
+// its status.
+func (r *Runner) AfterStep(f func(*StepInfo)) {
+    r.hooks.afterStep = append(r.hooks.afterStep, f)
+}
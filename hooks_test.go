@@ -0,0 +1,367 @@
+package gherkin
+
+import (
+    "bytes"
+    "context"
+    "strings"
+    "testing"
+    . "github.com/tychofreeman/go-matchers"
+)
+
+func TestHooksRunAroundFeaturesScenariosAndSteps(t *testing.T) {
+    calls := []string{}
+    record := func(s string) { calls = append(calls, s) }
+    g := createWriterlessRunner()
+    g.SetSetUpFn(func() { record("setUp") })
+    g.SetTearDownFn(func() { record("tearDown") })
+    g.BeforeFeature(func(f *Feature) { record("beforeFeature " + f.Name) })
+    g.AfterFeature(func(f *Feature) { record("afterFeature " + f.Name) })
+    g.BeforeScenario(func(s *ScenarioInfo) { record("beforeScenario " + s.Name + " " + strings.Join(s.Tags, " ")) })
+    g.AfterScenario(func(s *ScenarioInfo) { record("afterScenario " + s.Name) })
+    g.BeforeStep(func(s *StepInfo) { record("beforeStep " + s.Keyword + s.Text) })
+    g.AfterStep(func(s *StepInfo) { record("afterStep " + s.Text + " " + string(s.Status)) })
+    g.RegisterStepDef("^it works$", func(w *World) { record("step") })
+    g.Execute(`Feature: hooks
+        @tagged
+        Scenario: first
+            Given it works
+            Then it is undefined
+    `)
+
+    AssertThat(t, calls, Equals([]string{
+        "beforeFeature hooks",
+        "setUp",
+        "beforeScenario first @tagged",
+        "beforeStep Given it works",
+        "step",
+        "afterStep it works passed",
+        "beforeStep Then it is undefined",
+        "afterStep it is undefined undefined",
+        "afterScenario first",
+        "tearDown",
+        "afterFeature hooks",
+    }))
+}
+
+type hookKey struct{}
+
+func TestHooksSeeTheScenariosContext(t *testing.T) {
+    seen := []interface{}{}
+    g := createWriterlessRunner()
+    g.ctx = context.WithValue(context.Background(), hookKey{}, "run")
+    g.SetSetUpFn(func() { seen = append(seen, g.Context().Value(hookKey{})) })
+    g.BeforeScenario(func(s *ScenarioInfo) { seen = append(seen, s.Context.Value(hookKey{})) })
+    g.BeforeStep(func(s *StepInfo) { seen = append(seen, s.Context.Value(hookKey{})) })
+    g.AfterStep(func(s *StepInfo) { seen = append(seen, s.Context.Value(hookKey{})) })
+    g.AfterScenario(func(s *ScenarioInfo) { seen = append(seen, s.Context.Value(hookKey{})) })
+    g.RegisterStepDef("^it works$", func(ctx context.Context) context.Context {
+        return context.WithValue(ctx, hookKey{}, "step")
+    })
+    g.Execute(`Feature:
+        Scenario:
+            Given it works
+    `)
+
+    AssertThat(t, seen, Equals([]interface{}{"run", "run", "run", "step", "step"}))
+}
+
+func TestAfterHooksRunInReverseOrder(t *testing.T) {
+    calls := []string{}
+    g := createWriterlessRunner()
+    g.BeforeScenario(func(s *ScenarioInfo) { calls = append(calls, "before 1") })
+    g.BeforeScenario(func(s *ScenarioInfo) { calls = append(calls, "before 2") })
+    g.AfterScenario(func(s *ScenarioInfo) { calls = append(calls, "after 1") })
+    g.AfterScenario(func(s *ScenarioInfo) { calls = append(calls, "after 2") })
+    g.Execute(`Feature:
+        Scenario:
+    `)
+
+    AssertThat(t, calls, Equals([]string{"before 1", "before 2", "after 2", "after 1"}))
+}
+
+func TestAfterStepHookSeesTheStepsError(t *testing.T) {
+    var got *StepInfo
+    g := createWriterlessRunner()
+    g.AfterStep(func(s *StepInfo) { got = s })
+    g.RegisterStepDef("^it fails$", func(w *World) { w.Errorf("it failed") })
+    g.Execute(`Feature:
+        Scenario:
+            Then it fails
+    `)
+
+    AssertThat(t, got.Status, Equals(StepFailed))
+    AssertThat(t, got.Err.Error(), Equals("it failed"))
+    AssertThat(t, got.Location, Equals(Location{3, 13}))
+}
+
+func TestHookFailuresAreReportedApartFromSteps(t *testing.T) {
+    var buf bytes.Buffer
+    g := createWriterlessRunner()
+    g.SetOutput(&buf)
+    g.AfterStep(func(s *StepInfo) { panic("cannot clean up") })
+    g.RegisterStepDef("^it works$", func(w *World) { })
+    rpt := g.Execute(`Feature:
+        Scenario:
+            Given it works
+    `)
+
+    AssertThat(t, rpt.passedSteps, Equals(1))
+    AssertThat(t, rpt.failedSteps, Equals(0))
+    AssertThat(t, rpt.hookFailures, Equals(1))
+    AssertThat(t, strings.Contains(buf.String(), "HOOK FAILED - AfterStep: cannot clean up"), IsTrue)
+}
+
+func TestFailingBeforeScenarioHookSkipsTheSteps(t *testing.T) {
+    afterWasCalled := false
+    g := createWriterlessRunner()
+    g.BeforeScenario(func(s *ScenarioInfo) { panic("no database") })
+    g.AfterScenario(func(s *ScenarioInfo) { afterWasCalled = true })
+    g.RegisterStepDef("^it works$", func(w *World) { })
+    rpt := g.Execute(`Feature:
+        Scenario:
+            Given it works
+    `)
+
+    AssertThat(t, rpt.skippedSteps, Equals(1))
+    AssertThat(t, rpt.hookFailures, Equals(1))
+    AssertThat(t, afterWasCalled, IsTrue)
+}
+
+func TestSuiteHooksRunOncePerRun(t *testing.T) {
+    calls := []string{}
+    g := createWriterlessRunner()
+    g.BeforeSuite(func() { calls = append(calls, "before") })
+    g.AfterSuite(func() { calls = append(calls, "after 1") })
+    g.AfterSuite(func() { calls = append(calls, "after 2") })
+    c := &errorCollector{}
+    g.Run(c)
+
+    AssertThat(t, calls, Equals([]string{"before", "after 2", "after 1"}))
+    AssertThat(t, len(c.messages), Equals(0))
+}
+
+func TestFailingSuiteHookFailsTheRun(t *testing.T) {
+    g := createWriterlessRunner()
+    g.AfterSuite(func() { panic("cannot stop server") })
+    c := &errorCollector{}
+    g.Run(c)
+
+    AssertThat(t, len(c.messages), Equals(1))
+}
+
+func TestTaggedHooksOnlyRunForMatchingScenarios(t *testing.T) {
+    calls := []string{}
+    g := createWriterlessRunner()
+    g.Before("@database", func(s *ScenarioInfo) { calls = append(calls, "before " + s.Name) })
+    g.After("@database and not @readonly", func(s *ScenarioInfo) { calls = append(calls, "after " + s.Name) })
+    g.Before("", func(s *ScenarioInfo) { calls = append(calls, "every " + s.Name) })
+    g.Execute(`Feature:
+        @database
+        Scenario: writes
+        @database @readonly
+        Scenario: reads
+        Scenario: plain
+    `)
+
+    AssertThat(t, calls, Equals([]string{
+        "before writes", "every writes", "after writes",
+        "before reads", "every reads",
+        "every plain",
+    }))
+}
+
+func TestTaggedAndUntaggedHooksRunInRegistrationOrder(t *testing.T) {
+    calls := []string{}
+    g := createWriterlessRunner()
+    g.BeforeScenario(func(s *ScenarioInfo) { calls = append(calls, "before 1") })
+    g.Before("@a", func(s *ScenarioInfo) { calls = append(calls, "before 2") })
+    g.BeforeScenario(func(s *ScenarioInfo) { calls = append(calls, "before 3") })
+    g.After("@a", func(s *ScenarioInfo) { calls = append(calls, "after 1") })
+    g.AfterScenario(func(s *ScenarioInfo) { calls = append(calls, "after 2") })
+    g.Execute(`Feature:
+        @a
+        Scenario:
+    `)
+
+    AssertThat(t, calls, Equals([]string{"before 1", "before 2", "before 3", "after 2", "after 1"}))
+}
+
+func TestAroundHooksWrapTheScenario(t *testing.T) {
+    calls := []string{}
+    g := createWriterlessRunner()
+    g.SetSetUpFn(func() { calls = append(calls, "setUp") })
+    g.SetTearDownFn(func() { calls = append(calls, "tearDown") })
+    g.Around("", func(s *ScenarioInfo, run func()) {
+        calls = append(calls, "outer start")
+        run()
+        calls = append(calls, "outer end")
+    })
+    g.Around("@inner", func(s *ScenarioInfo, run func()) {
+        calls = append(calls, "inner start")
+        run()
+        calls = append(calls, "inner end")
+    })
+    g.BeforeScenario(func(s *ScenarioInfo) { calls = append(calls, "before") })
+    g.AfterScenario(func(s *ScenarioInfo) { calls = append(calls, "after") })
+    g.RegisterStepDef("^it works$", func(w *World) { calls = append(calls, "step") })
+    rpt := g.Execute(`Feature:
+        @inner
+        Scenario:
+            Given it works
+    `)
+
+    AssertThat(t, calls, Equals([]string{
+        "setUp", "outer start", "inner start",
+        "before", "step", "after",
+        "inner end", "outer end", "tearDown",
+    }))
+    AssertThat(t, rpt.passedSteps, Equals(1))
+}
+
+func TestAroundHookMayDecideNotToRunTheScenario(t *testing.T) {
+    wasCalled := false
+    var info *ScenarioInfo
+    g := createWriterlessRunner()
+    g.Around("@skip", func(s *ScenarioInfo, run func()) { info = s })
+    g.RegisterStepDef("^it works$", func(w *World) { wasCalled = true })
+    rpt := g.Execute(`Feature:
+        Background:
+            Given it works
+        @skip
+        Scenario:
+            Given it works
+    `)
+
+    AssertThat(t, wasCalled, IsFalse)
+    AssertThat(t, rpt.skippedScenarios, Equals(1))
+    AssertThat(t, rpt.skippedSteps, Equals(2))
+    AssertThat(t, info.Result.Status, Equals(StepSkipped))
+}
+
+func TestAroundHookWhichPanicsBeforeRunningTheScenarioFailsIt(t *testing.T) {
+    var info *ScenarioInfo
+    g := createWriterlessRunner()
+    g.Around("", func(s *ScenarioInfo, run func()) {
+        info = s
+        panic("no database")
+    })
+    g.RegisterStepDef("^it works$", func(w *World) { })
+    rpt := g.Execute(`Feature:
+        Scenario:
+            Given it works
+    `)
+
+    AssertThat(t, rpt.hookFailures, Equals(1))
+    AssertThat(t, rpt.skippedScenarios, Equals(0))
+    AssertThat(t, rpt.skippedSteps, Equals(1))
+    AssertThat(t, info.Result.Status, Equals(StepFailed))
+    AssertThat(t, len(rpt.unsuccessful), Equals(1))
+}
+
+func TestInvalidHookTagExpressionIsReportedByRun(t *testing.T) {
+    g := createWriterlessRunner()
+    g.Before("@a and", func(s *ScenarioInfo) { })
+    c := &errorCollector{}
+    g.Run(c)
+
+    AssertThat(t, len(c.messages), Equals(1))
+    AssertThat(t, strings.HasPrefix(c.messages[0], "hooks_test.go:"), IsTrue)
+    AssertThat(t, strings.Contains(c.messages[0], `cannot register hook "@a and"`), IsTrue)
+}
+
+func TestTearDownAndAfterHooksRunWhenAStepPanics(t *testing.T) {
+    calls := []string{}
+    g := createWriterlessRunner()
+    g.SetTearDownFn(func() { calls = append(calls, "tearDown") })
+    g.AfterScenario(func(s *ScenarioInfo) { calls = append(calls, "after") })
+    g.RegisterStepDef("^it blows up$", func(w *World) { panic("boom") })
+    g.Execute(`Feature:
+        Scenario:
+            Given it blows up
+    `)
+
+    AssertThat(t, calls, Equals([]string{"after", "tearDown"}))
+}
+
+func TestTearDownAndAfterHooksRunWhenTheWorldFactoryPanics(t *testing.T) {
+    calls := []string{}
+    var result *ScenarioResult
+    g := createWriterlessRunner()
+    g.SetWorldFactory(func() interface{} { panic("no world") })
+    g.SetTearDownFn(func() { calls = append(calls, "tearDown") })
+    g.AfterScenario(func(s *ScenarioInfo) {
+        calls = append(calls, "after")
+        result = s.Result
+    })
+    g.RegisterStepDef("^it works$", func(w *World) { calls = append(calls, "step") })
+    rpt := g.Execute(`Feature:
+        Scenario:
+            Given it works
+    `)
+
+    AssertThat(t, calls, Equals([]string{"after", "tearDown"}))
+    AssertThat(t, rpt.hookFailures, Equals(1))
+    AssertThat(t, rpt.skippedSteps, Equals(1))
+    AssertThat(t, result.Status, Equals(StepFailed))
+    AssertThat(t, result.Errors[0].Error(), Equals("WorldFactory hook failed: no world"))
+}
+
+func TestAfterHooksSeeTheScenarioResult(t *testing.T) {
+    var results []ScenarioResult
+    g := createWriterlessRunner()
+    g.AfterScenario(func(s *ScenarioInfo) { results = append(results, *s.Result) })
+    g.RegisterStepDef("^it works$", func(w *World) { })
+    g.RegisterStepDef("^it fails$", func(w *World) { w.Errorf("it failed") })
+    g.Execute(`Feature:
+        Scenario:
+            Given it works
+        Scenario:
+            Given it fails
+            Then it works
+        Scenario:
+            Given it is undefined
+    `)
+
+    AssertThat(t, len(results), Equals(3))
+    AssertThat(t, results[0].Status, Equals(StepPassed))
+    AssertThat(t, len(results[0].Errors), Equals(0))
+    AssertThat(t, results[1].Status, Equals(StepFailed))
+    AssertThat(t, results[1].Errors[0].Error(), Equals("it failed"))
+    AssertThat(t, results[2].Status, Equals(StepUndefined))
+}
+
+func TestFailingSetUpSkipsTheStepsButNotTheTearDown(t *testing.T) {
+    tornDown := false
+    var status StepStatus
+    g := createWriterlessRunner()
+    g.SetSetUpFn(func() { panic("no database") })
+    g.SetTearDownFn(func() { tornDown = true })
+    g.AfterScenario(func(s *ScenarioInfo) { status = s.Result.Status })
+    g.RegisterStepDef("^it works$", func(w *World) { })
+    rpt := g.Execute(`Feature:
+        Scenario:
+            Given it works
+    `)
+
+    AssertThat(t, rpt.skippedSteps, Equals(1))
+    AssertThat(t, rpt.hookFailures, Equals(1))
+    AssertThat(t, status, Equals(StepFailed))
+    AssertThat(t, tornDown, IsTrue)
+}
+
+func TestTearDownErrorsAreAttachedToTheScenarioResult(t *testing.T) {
+    var info *ScenarioInfo
+    g := createWriterlessRunner()
+    g.BeforeScenario(func(s *ScenarioInfo) { info = s })
+    g.SetTearDownFn(func() { panic("cannot drop tables") })
+    g.RegisterStepDef("^it works$", func(w *World) { })
+    rpt := g.Execute(`Feature:
+        Scenario:
+            Given it works
+    `)
+
+    AssertThat(t, rpt.passedSteps, Equals(1))
+    AssertThat(t, rpt.hookFailures, Equals(1))
+    AssertThat(t, info.Result.Status, Equals(StepFailed))
+    AssertThat(t, info.Result.Errors[0].Error(), Equals("TearDown hook failed: cannot drop tables"))
+}
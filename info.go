@@ -0,0 +1,106 @@
+package gherkin
+
+import (
+    "context"
+)
+
+// Describes the scenario being executed. Step definitions can get
+// at it through World.Scenario().
+type ScenarioInfo struct {
+    Name string
+    Location Location
+    // The name of the enclosing Rule, if there is one.
+    Rule string
+    // Includes the tags of the enclosing Feature and Rule (and of the Examples,
+    // for scenarios generated from an outline), without duplicates.
+    Tags []string
+    // How the scenario went. It is set before the scenario starts, and its
+    // Errors grow as steps and hooks fail, but its Status is empty until
+    // the steps have run. So steps and Before hooks see the errors so far,
+    // and After hooks also see the Status. Failures of After hooks and of
+    // the tear-down function are added last.
+    Result *ScenarioResult
+    // The scenario's context, as in World.Context(): the run's context,
+    // until a step returns another.
+    Context context.Context
+}
+
+// The outcome of a scenario, including the failures of its hooks and of
+// its tear-down function.
+type ScenarioResult struct {
+    // Passed only if every step passed and no hook failed.
+    Status StepStatus
+    // Why each step, or hook, failed, in the order they failed.
+    Errors []error
+}
+
+// The status of a scenario is the worst status of its steps.
+func scenarioStatus(rpt Report) StepStatus {
+    switch {
+    case rpt.failedSteps > 0 || rpt.hookFailures > 0:
+        return StepFailed
+    case rpt.ambiguousSteps > 0:
+        return StepAmbiguous
+    case rpt.undefinedSteps > 0:
+        return StepUndefined
+    case rpt.pendingSteps > 0:
+        return StepPending
+    case rpt.skippedSteps > 0:
+        return StepSkipped
+    }
+    return StepPassed
+}
+
+// Whether the scenario, or anything enclosing it, carries the given tag.
+// The leading '@' is required.
+func (s *ScenarioInfo) HasTag(tag string) bool {
+    for _, t := range s.Tags {
+        if t == tag {
+            return true
+        }
+    }
+    return false
+}
+
+// Flattens the tags of a node and of its ancestors, outermost first.
+func inheritTags(tagLists ...[]*Tag) []string {
+    names := []string{}
+    seen := map[string]bool{}
+    for _, tags := range tagLists {
+        for _, t := range tags {
+            if !seen[t.Name] {
+                seen[t.Name] = true
+                names = append(names, t.Name)
+            }
+        }
+    }
+    return names
+}
+
+// The result of a step, as seen by step hooks.
+type StepStatus string
+
+const (
+    StepPassed StepStatus = "passed"
+    StepFailed StepStatus = "failed"
+    StepPending StepStatus = "pending"
+    StepUndefined StepStatus = "undefined"
+    StepAmbiguous StepStatus = "ambiguous"
+    StepSkipped StepStatus = "skipped"
+)
+
+// Describes the step being executed, for step hooks. Status and Err are
+// only set once the step has run, for AfterStep hooks.
+type StepInfo struct {
+    Keyword string
+    Text string
+    Location Location
+    Scenario *ScenarioInfo
+    // The scenario's context when the hook is called. AfterStep hooks see
+    // the context which the step returned.
+    Context context.Context
+    Status StepStatus
+    // What the step wrote through World.Errorf(), or why it could not
+    // be run, if it did not pass.
+    Err error
+}
@@ -0,0 +1,67 @@
+package gherkin
+
+import (
+    _ "embed"
+    "encoding/json"
+    "regexp"
+)
+
+// The keywords of each spoken language, from Cucumber's
+// gherkin-languages.json, unchanged.
+//go:embed gherkin-languages.json
+var languagesJSON []byte
+
+type languageKeywords struct {
+    Name string `json:"name"`
+    Native string `json:"native"`
+    Feature []string `json:"feature"`
+    Rule []string `json:"rule"`
+    Background []string `json:"background"`
+    Scenario []string `json:"scenario"`
+    ScenarioOutline []string `json:"scenarioOutline"`
+    Examples []string `json:"examples"`
+    Given []string `json:"given"`
+    When []string `json:"when"`
+    Then []string `json:"then"`
+    And []string `json:"and"`
+    But []string `json:"but"`
+}
+
+var dialects = loadDialects(languagesJSON)
+
+func loadDialects(data []byte) map[string]*dialect {
+    languages := map[string]languageKeywords{}
+    if err := json.Unmarshal(data, &languages); err != nil {
+        panic("gherkin-languages.json is malformed: " + err.Error())
+    }
+    result := map[string]*dialect{}
+    for lang, kw := range languages {
+        result[lang] = &dialect{
+            language: lang,
+            feature: kw.Feature,
+            rule: kw.Rule,
+            background: kw.Background,
+            scenario: kw.Scenario,
+            scenarioOutline: kw.ScenarioOutline,
+            examples: kw.Examples,
+            given: kw.Given,
+            when: kw.When,
+            then: kw.Then,
+            and: kw.And,
+            but: kw.But,
+        }
+    }
+    return result
+}
+
+const defaultLanguage = "en"
+
+var languageHeader = regexp.MustCompile(`^\s*#\s*language\s*:\s*([a-zA-Z\-_]+)\s*$`)
+
+// The language named in a "# language: xx" comment, if the line is one.
+func parseLanguageHeader(line string) (string, bool) {
+    if m := languageHeader.FindStringSubmatch(line); m != nil {
+        return m[1], true
+    }
+    return "", false
+}
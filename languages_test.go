@@ -0,0 +1,107 @@
+package gherkin
+
+import (
+    "strings"
+    "testing"
+    . "github.com/tychofreeman/go-matchers"
+)
+
+func TestParsesFeatureInLanguageFromHeader(t *testing.T) {
+    f := parseString(t, `# language: de
+Funktionalität: Einkaufen
+  Grundlage:
+    Angenommen ein leerer Warenkorb
+  Szenariogrundriss: kaufen
+    Wenn ich <n> Äpfel kaufe
+    Dann habe ich <n> Äpfel
+  Beispiele:
+    | n |
+    | 2 |`)
+
+    AssertThat(t, f.Language, Equals("de"))
+    AssertThat(t, f.Keyword, Equals("Funktionalität"))
+    AssertThat(t, f.Background.Steps[0].Keyword, Equals("Angenommen "))
+    AssertThat(t, f.ScenarioDefinitions[0].Definition().Steps[1].Text, Equals("habe ich <n> Äpfel"))
+}
+
+func TestReportsUnknownLanguage(t *testing.T) {
+    _, err := Parse(strings.NewReader("# language: xx-nowhere\nFeature:"))
+
+    AssertThat(t, err.(ParseErrors)[0].Message, Equals(`unknown language "xx-nowhere"`))
+}
+
+func TestRunnerUsesDefaultLanguage(t *testing.T) {
+    g := createWriterlessRunner()
+    AssertThat(t, g.SetDefaultLanguage("pt"), Equals(nil))
+    calls := []string{}
+    g.RegisterStepDef("^(.*)$", func(w *World) { calls = append(calls, w.GetRegexParam()) })
+    g.Execute(`Funcionalidade: compras
+    Cenário: comprar
+        Dado um carrinho vazio
+        Quando eu compro 2 maçãs
+        Então tenho 2 maçãs`)
+
+    AssertThat(t, calls, Equals([]string{"um carrinho vazio", "eu compro 2 maçãs", "tenho 2 maçãs"}))
+}
+
+func TestLanguageHeaderOverridesDefaultLanguage(t *testing.T) {
+    g := createWriterlessRunner()
+    g.SetDefaultLanguage("pt")
+    wasCalled := false
+    g.RegisterStepDef("^a thing$", func(w *World) { wasCalled = true })
+    g.Execute(`# language: en
+Feature:
+    Scenario:
+        Given a thing`)
+
+    AssertThat(t, wasCalled, IsTrue)
+}
+
+func TestSetDefaultLanguageRejectsUnknownLanguage(t *testing.T) {
+    g := createWriterlessRunner()
+
+    AssertThat(t, g.SetDefaultLanguage("klingon") != nil, IsTrue)
+}
+
+// Writes a feature with a background, a scenario and an outline, using the
+// last of each of the dialect's keywords, since the first step keyword of
+// each type is "* ".
+func featureInDialect(d *dialect) string {
+    last := func(keywords []string) string { return keywords[len(keywords)-1] }
+    return "# language: " + d.language + "\n" +
+        last(d.feature) + ": f\n" +
+        last(d.background) + ":\n" +
+        "  " + last(d.given) + "b\n" +
+        last(d.scenario) + ": s\n" +
+        "  " + last(d.given) + "g\n" +
+        "  " + last(d.when) + "w\n" +
+        "  " + last(d.then) + "t\n" +
+        "  " + last(d.and) + "a\n" +
+        "  " + last(d.but) + "u\n" +
+        last(d.scenarioOutline) + ": o\n" +
+        "  " + last(d.given) + "<x>\n" +
+        last(d.examples) + ":\n" +
+        "  | x |\n" +
+        "  | 1 |\n"
+}
+
+func TestLoadsEveryDialect(t *testing.T) {
+    AssertThat(t, len(dialects) > 70, IsTrue)
+    for lang, d := range dialects {
+        for _, keywords := range [][]string{d.feature, d.rule, d.background, d.scenario, d.scenarioOutline, d.examples, d.given, d.when, d.then, d.and, d.but} {
+            if len(keywords) == 0 {
+                t.Errorf("%s is missing keywords", lang)
+            }
+        }
+
+        f, err := Parse(strings.NewReader(featureInDialect(d)))
+        if err != nil {
+            t.Errorf("%s: %v", lang, err)
+            continue
+        }
+        AssertThat(t, f.Language, Equals(lang))
+        AssertThat(t, len(f.Background.Steps), Equals(1))
+        AssertThat(t, len(f.ScenarioDefinitions), Equals(2))
+        AssertThat(t, len(f.ScenarioDefinitions[0].Definition().Steps), Equals(5))
+    }
+}
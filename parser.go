@@ -0,0 +1,514 @@
+package gherkin
+
+import (
+    "fmt"
+    "io"
+    "io/ioutil"
+    "os"
+    "regexp"
+    "sort"
+    "strings"
+    "unicode"
+)
+
+// The keywords of a single spoken language.
+type dialect struct {
+    language string
+    feature []string
+    rule []string
+    background []string
+    scenario []string
+    scenarioOutline []string
+    examples []string
+    given []string
+    when []string
+    then []string
+    and []string
+    but []string
+}
+
+func (d *dialect) stepKeywords() []string {
+    all := [][]string{d.given, d.when, d.then, d.and, d.but}
+    keywords := []string{}
+    for _, kws := range all {
+        keywords = append(keywords, kws...)
+    }
+    // Longest first, so that no keyword is mistaken for a shorter one
+    // which happens to be its prefix.
+    sort.SliceStable(keywords, func(i, j int) bool {
+        return len(keywords[i]) > len(keywords[j])
+    })
+    return keywords
+}
+
+func matchTitle(keywords []string, text string) (string, string, bool) {
+    for _, kw := range keywords {
+        if strings.HasPrefix(text, kw + ":") {
+            return kw, strings.TrimSpace(text[len(kw)+1:]), true
+        }
+    }
+    return "", "", false
+}
+
+// The type of a step keyword. A keyword listed under more than one type,
+// such as "* ", is unknown.
+func (d *dialect) keywordType(keyword string) KeywordType {
+    types := map[KeywordType][]string{
+        ContextKeyword: d.given,
+        ActionKeyword: d.when,
+        OutcomeKeyword: d.then,
+        ConjunctionKeyword: append(append([]string{}, d.and...), d.but...),
+    }
+    found := UnknownKeyword
+    for kt, keywords := range types {
+        for _, kw := range keywords {
+            if kw != keyword {
+                continue
+            }
+            if found != UnknownKeyword && found != kt {
+                return UnknownKeyword
+            }
+            found = kt
+        }
+    }
+    return found
+}
+
+func (d *dialect) matchStep(text string) (string, string, bool) {
+    for _, kw := range d.stepKeywords() {
+        if strings.HasPrefix(text, kw) {
+            return kw, strings.TrimSpace(text[len(kw):]), true
+        }
+    }
+    return "", "", false
+}
+
+type parser struct {
+    dialect *dialect
+    feature *Feature
+    rule *Rule
+    outline *ScenarioOutline
+    examples *Examples
+    steps *[]*Step
+    step *Step
+    description *string
+    tags []*Tag
+    docString *DocString
+    docStringLines []string
+    comments []*Comment
+    file string
+    errors ParseErrors
+}
+
+// Parse reads a single feature file and returns its syntax tree. Nothing
+// is executed; use Runner.ExecuteFeature() for that.
+//
+// Keywords are English unless the file starts with a comment such as
+// "# language: de".
+//
+// Parsing does not stop at the first problem. If there are any, the error
+// is a ParseErrors holding all of them, and the returned Feature holds
+// whatever could be understood.
+func Parse(in io.Reader) (*Feature, error) {
+    return parse("", in, defaultLanguage)
+}
+
+// Like Parse(), but reads the named file, whose name is then used in any
+// ParseError.
+func ParseFile(path string) (*Feature, error) {
+    return parseFile(path, defaultLanguage)
+}
+
+func parseFile(path, language string) (*Feature, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+    return parse(path, file, language)
+}
+
+func parse(name string, in io.Reader, language string) (*Feature, error) {
+    data, err := ioutil.ReadAll(in)
+    if err != nil {
+        return nil, err
+    }
+    p := &parser{file: name}
+    p.setLanguage(Location{1, 1}, language)
+    for i, line := range strings.Split(string(data), "\n") {
+        p.parseLine(i+1, strings.TrimRight(line, "\r"))
+    }
+    if p.docString != nil {
+        p.errorf(p.docString.Location, "unterminated doc string")
+    }
+    p.rejectTags()
+    if p.feature != nil {
+        p.feature.Comments = p.comments
+    }
+    sort.SliceStable(p.errors, func(i, j int) bool {
+        return p.errors[i].Line < p.errors[j].Line
+    })
+    if len(p.errors) > 0 {
+        return p.feature, p.errors
+    }
+    return p.feature, nil
+}
+
+func (p *parser) setLanguage(loc Location, language string) {
+    if d, ok := dialects[language]; ok {
+        p.dialect = d
+    } else {
+        p.errorf(loc, "unknown language %q", language)
+        if p.dialect == nil {
+            p.dialect = dialects[defaultLanguage]
+        }
+    }
+}
+
+func (p *parser) errorf(loc Location, format string, args ...interface{}) {
+    p.errors = append(p.errors, &ParseError{p.file, loc, fmt.Sprintf(format, args...)})
+}
+
+// Both the column and the text are computed in runes, not bytes.
+func indentation(line string) int {
+    return len([]rune(line)) - len([]rune(strings.TrimLeftFunc(line, unicode.IsSpace)))
+}
+
+func (p *parser) parseLine(lineNo int, line string) {
+    if p.docString != nil {
+        p.parseDocStringLine(line)
+        return
+    }
+    text := strings.TrimSpace(line)
+    loc := Location{lineNo, indentation(line) + 1}
+    d := p.dialect
+    if text == "" {
+        return
+    } else if lang, ok := parseLanguageHeader(text); ok && p.feature == nil {
+        p.setLanguage(loc, lang)
+    } else if strings.HasPrefix(text, "#") {
+        p.comments = append(p.comments, &Comment{loc, text})
+    } else if strings.HasPrefix(text, "@") {
+        p.tags = append(p.tags, parseTags(loc, line)...)
+    } else if strings.HasPrefix(text, `"""`) || strings.HasPrefix(text, "```") {
+        p.startDocString(loc, text)
+    } else if strings.HasPrefix(text, "|") {
+        if !strings.HasSuffix(text, "|") {
+            p.errorf(loc, "table row must end with '|'")
+        }
+        p.addTableRow(parseTableRow(loc, line))
+    } else if kw, name, ok := matchTitle(d.feature, text); ok {
+        p.startFeature(loc, kw, name)
+    } else if kw, name, ok := matchTitle(d.rule, text); ok {
+        p.startRule(loc, kw, name)
+    } else if kw, name, ok := matchTitle(d.background, text); ok {
+        p.startBackground(loc, kw, name)
+    } else if kw, name, ok := matchTitle(d.scenarioOutline, text); ok {
+        p.startScenarioOutline(loc, kw, name)
+    } else if kw, name, ok := matchTitle(d.scenario, text); ok {
+        p.startScenario(loc, kw, name)
+    } else if kw, name, ok := matchTitle(d.examples, text); ok {
+        p.startExamples(loc, kw, name)
+    } else if kw, stepText, ok := d.matchStep(text); ok {
+        p.addStep(loc, kw, stepText)
+    } else if p.description != nil && len(p.tags) == 0 {
+        if len(*p.description) > 0 {
+            *p.description += "\n"
+        }
+        *p.description += text
+    } else {
+        p.rejectTags()
+        p.errorf(loc, "unexpected text %q", text)
+    }
+}
+
+// Tags must be followed by something which can be tagged. Any others are
+// reported and discarded.
+func (p *parser) rejectTags() {
+    if len(p.tags) > 0 {
+        p.errorf(p.tags[0].Location, "tags must be followed by a Feature, Rule, Scenario, Scenario Outline or Examples")
+        p.tags = nil
+    }
+}
+
+func (p *parser) takeTags() []*Tag {
+    tags := p.tags
+    p.tags = nil
+    return tags
+}
+
+// Resets everything below the feature.
+func (p *parser) resetChildren() {
+    p.outline = nil
+    p.examples = nil
+    p.steps = nil
+    p.step = nil
+    p.description = nil
+}
+
+func (p *parser) startFeature(loc Location, keyword, name string) {
+    if p.feature != nil {
+        p.errorf(loc, "only one %s is allowed per file", keyword)
+        p.tags = nil
+        return
+    }
+    p.resetChildren()
+    p.feature = &Feature{Location: loc, Language: p.dialect.language, Tags: p.takeTags(), Keyword: keyword, Name: name}
+    p.description = &p.feature.Description
+}
+
+// Where backgrounds and scenarios go: the latest Rule if there is one,
+// since a Rule lasts until the end of the file, otherwise the Feature.
+func (p *parser) container() (**Background, *[]Definition) {
+    if p.rule != nil {
+        return &p.rule.Background, &p.rule.ScenarioDefinitions
+    }
+    return &p.feature.Background, &p.feature.ScenarioDefinitions
+}
+
+func (p *parser) startRule(loc Location, keyword, name string) {
+    if p.feature == nil {
+        p.errorf(loc, "%s must follow a Feature", keyword)
+        p.tags = nil
+        return
+    }
+    p.resetChildren()
+    p.rule = &Rule{Location: loc, Tags: p.takeTags(), Keyword: keyword, Name: name}
+    p.feature.Rules = append(p.feature.Rules, p.rule)
+    p.description = &p.rule.Description
+}
+
+func (p *parser) startBackground(loc Location, keyword, name string) {
+    p.rejectTags()
+    if p.feature == nil {
+        p.errorf(loc, "%s must follow a Feature", keyword)
+        return
+    }
+    background, definitions := p.container()
+    if *background != nil {
+        p.errorf(loc, "only one %s is allowed per Feature or Rule", keyword)
+        return
+    } else if len(*definitions) > 0 {
+        p.errorf(loc, "%s must come before any Scenario", keyword)
+        return
+    }
+    p.resetChildren()
+    bg := &Background{Location: loc, Keyword: keyword, Name: name}
+    *background = bg
+    p.steps = &bg.Steps
+    p.description = &bg.Description
+}
+
+func (p *parser) startDefinition(loc Location, keyword, name string) ScenarioDefinition {
+    p.resetChildren()
+    return ScenarioDefinition{Location: loc, Tags: p.takeTags(), Keyword: keyword, Name: name}
+}
+
+func (p *parser) startScenario(loc Location, keyword, name string) {
+    if p.feature == nil {
+        p.errorf(loc, "%s must follow a Feature", keyword)
+        p.tags = nil
+        return
+    }
+    s := &Scenario{p.startDefinition(loc, keyword, name)}
+    _, definitions := p.container()
+    *definitions = append(*definitions, s)
+    p.steps = &s.Steps
+    p.description = &s.Description
+}
+
+func (p *parser) startScenarioOutline(loc Location, keyword, name string) {
+    if p.feature == nil {
+        p.errorf(loc, "%s must follow a Feature", keyword)
+        p.tags = nil
+        return
+    }
+    so := &ScenarioOutline{ScenarioDefinition: p.startDefinition(loc, keyword, name)}
+    _, definitions := p.container()
+    *definitions = append(*definitions, so)
+    p.outline = so
+    p.steps = &so.Steps
+    p.description = &so.Description
+}
+
+func (p *parser) startExamples(loc Location, keyword, name string) {
+    if p.outline == nil {
+        p.errorf(loc, "%s must follow a Scenario Outline", keyword)
+        p.tags = nil
+        return
+    }
+    ex := &Examples{Location: loc, Tags: p.takeTags(), Keyword: keyword, Name: name}
+    p.outline.Examples = append(p.outline.Examples, ex)
+    p.examples = ex
+    p.steps = nil
+    p.step = nil
+    p.description = &ex.Description
+}
+
+func (p *parser) addStep(loc Location, keyword, text string) {
+    p.rejectTags()
+    p.description = nil
+    if p.steps == nil {
+        p.errorf(loc, "step %q must follow a Scenario or Background", strings.TrimSpace(keyword) + " " + text)
+        p.step = nil
+        return
+    }
+    p.step = &Step{Location: loc, Keyword: keyword, KeywordType: p.dialect.keywordType(keyword), Text: text}
+    *p.steps = append(*p.steps, p.step)
+}
+
+func (p *parser) addTableRow(row *TableRow) {
+    p.rejectTags()
+    p.description = nil
+    if p.step != nil && p.step.DocString == nil {
+        if p.step.DataTable == nil {
+            p.step.DataTable = &DataTable{Location: row.Location}
+        }
+        table := p.step.DataTable
+        if len(table.Rows) > 0 && len(table.Rows[0].Cells) != len(row.Cells) {
+            p.errorf(row.Location, "inconsistent cell count - expected %d fields but found %d", len(table.Rows[0].Cells), len(row.Cells))
+            return
+        }
+        table.Rows = append(table.Rows, row)
+    } else if p.examples != nil {
+        if p.examples.TableHeader == nil {
+            p.examples.TableHeader = row
+            p.checkPlaceholders(row)
+        } else if len(p.examples.TableHeader.Cells) != len(row.Cells) {
+            p.errorf(row.Location, "inconsistent cell count - expected %d fields but found %d", len(p.examples.TableHeader.Cells), len(row.Cells))
+        } else {
+            p.examples.TableBody = append(p.examples.TableBody, row)
+        }
+    } else {
+        p.errorf(row.Location, "table row must follow a step or Examples")
+    }
+}
+
+// Only names which look like identifiers are checked, so that text such
+// as "1 <2 and 3> 0" is left alone. Placeholders with other names are
+// still replaced when the outline is run.
+var placeholderPattern = regexp.MustCompile(`<([\p{L}_][\p{L}\p{N}_\-.]*)>`)
+
+// Every <placeholder> in an outline, whether in its name, its steps or
+// their tables, must name a column of each of its Examples tables. Doc
+// strings are not checked, since they often hold markup such as XML.
+func (p *parser) checkPlaceholders(header *TableRow) {
+    columns := map[string]bool{}
+    for _, k := range header.Values() {
+        columns[k] = true
+    }
+    check := func(loc Location, text string) {
+        for _, m := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+            if !columns[m[1]] {
+                p.errorf(loc, "placeholder <%s> has no matching column in the Examples at line %d", m[1], header.Line)
+            }
+        }
+    }
+    check(p.outline.Location, p.outline.Name)
+    for _, s := range p.outline.Steps {
+        check(s.Location, s.Text)
+        if s.DataTable != nil {
+            for _, row := range s.DataTable.Rows {
+                for _, cell := range row.Cells {
+                    check(cell.Location, cell.Value)
+                }
+            }
+        }
+    }
+}
+
+// Splits a line such as "  | a | b\|c |" into its cells. The escapes \|, \\
+// and \n are understood within a cell.
+func parseTableRow(loc Location, line string) *TableRow {
+    row := &TableRow{Location: loc}
+    runes := []rune(line)
+    start := loc.Column
+    var value []rune
+    for i := start; i < len(runes); i++ {
+        c := runes[i]
+        if c == '|' {
+            row.Cells = append(row.Cells, newTableCell(loc.Line, start, value))
+            start = i + 1
+            value = nil
+        } else if c == '\\' && i+1 < len(runes) {
+            i++
+            switch runes[i] {
+            case 'n':
+                value = append(value, '\n')
+            case '|', '\\':
+                value = append(value, runes[i])
+            default:
+                value = append(value, c, runes[i])
+            }
+        } else {
+            value = append(value, c)
+        }
+    }
+    return row
+}
+
+// 'start' is the zero-based index just after the opening '|'.
+func newTableCell(line, start int, value []rune) *TableCell {
+    leading := 0
+    for leading < len(value) && unicode.IsSpace(value[leading]) {
+        leading++
+    }
+    return &TableCell{Location{line, start + leading + 1}, strings.TrimSpace(string(value))}
+}
+
+func parseTags(loc Location, line string) []*Tag {
+    tags := []*Tag{}
+    runes := []rune(line)
+    for i := 0; i < len(runes); {
+        if unicode.IsSpace(runes[i]) {
+            i++
+            continue
+        }
+        if runes[i] == '#' {
+            break
+        }
+        start := i
+        for i < len(runes) && !unicode.IsSpace(runes[i]) {
+            i++
+        }
+        tags = append(tags, &Tag{Location{loc.Line, start + 1}, string(runes[start:i])})
+    }
+    return tags
+}
+
+func (p *parser) startDocString(loc Location, text string) {
+    p.rejectTags()
+    p.description = nil
+    if p.step == nil || p.step.DataTable != nil || p.step.DocString != nil {
+        p.errorf(loc, "doc string must follow a step")
+    }
+    p.docString = &DocString{Location: loc, Delimiter: text[:3], ContentType: strings.TrimSpace(text[3:])}
+    p.docStringLines = nil
+}
+
+// Removes up to 'indent' whitespace characters from the start of the line.
+func unindent(line string, indent int) string {
+    runes := []rune(line)
+    i := 0
+    for i < indent && i < len(runes) && unicode.IsSpace(runes[i]) {
+        i++
+    }
+    return string(runes[i:])
+}
+
+// Content lines are indented relative to the opening delimiter, and may
+// contain the delimiter itself if each of its characters is escaped.
+func (p *parser) parseDocStringLine(line string) {
+    if strings.TrimSpace(line) == p.docString.Delimiter {
+        p.docString.Content = strings.Join(p.docStringLines, "\n")
+        if p.step != nil && p.step.DataTable == nil && p.step.DocString == nil {
+            p.step.DocString = p.docString
+        }
+        p.docString = nil
+        return
+    }
+    delim := p.docString.Delimiter
+    escaped := strings.Repeat(`\` + delim[:1], 3)
+    line = strings.Replace(unindent(line, p.docString.Column - 1), escaped, delim, -1)
+    p.docStringLines = append(p.docStringLines, line)
+}
@@ -0,0 +1,334 @@
+package gherkin
+
+import (
+    "fmt"
+    "strings"
+    "testing"
+    . "github.com/tychofreeman/go-matchers"
+)
+
+func parseString(t *testing.T, text string) *Feature {
+    f, err := Parse(strings.NewReader(text))
+    if err != nil {
+        t.Fatalf("unexpected parse error: %v", err)
+    }
+    return f
+}
+
+func TestParsesFeatureNameAndDescription(t *testing.T) {
+    f := parseString(t, `Feature: My Feature
+        As a user
+        I want things`)
+
+    AssertThat(t, f.Keyword, Equals("Feature"))
+    AssertThat(t, f.Name, Equals("My Feature"))
+    AssertThat(t, f.Description, Equals("As a user\nI want things"))
+    AssertThat(t, f.Location, Equals(Location{1, 1}))
+}
+
+func TestParsesScenariosInOrder(t *testing.T) {
+    f := parseString(t, featureText)
+
+    AssertThat(t, len(f.ScenarioDefinitions), Equals(3))
+    AssertThat(t, f.ScenarioDefinitions[1].Definition().Name, Equals("Scenario 2"))
+}
+
+func TestParsesStepKeywordTextAndLocation(t *testing.T) {
+    f := parseString(t, featureText)
+    s := f.ScenarioDefinitions[2].Definition().Steps[1]
+
+    AssertThat(t, s.Keyword, Equals("When "))
+    AssertThat(t, s.Text, Equals("the third action has leading spaces"))
+    AssertThat(t, s.Location, Equals(Location{14, 9}))
+}
+
+func TestParsesStepKeywordTypes(t *testing.T) {
+    f := parseString(t, `Feature:
+    Scenario:
+        Given a
+        When b
+        Then c
+        And d
+        But e
+        * f`)
+    steps := f.ScenarioDefinitions[0].Definition().Steps
+
+    types := []KeywordType{}
+    for _, s := range steps {
+        types = append(types, s.KeywordType)
+    }
+    AssertThat(t, types, Equals([]KeywordType{ContextKeyword, ActionKeyword, OutcomeKeyword, ConjunctionKeyword, ConjunctionKeyword, UnknownKeyword}))
+}
+
+func TestParsesBackground(t *testing.T) {
+    f := parseString(t, `Feature:
+        Background:
+            Given background
+        Scenario:
+            Then this`)
+
+    AssertThat(t, len(f.Background.Steps), Equals(1))
+    AssertThat(t, f.Background.Steps[0].Text, Equals("background"))
+    AssertThat(t, len(f.ScenarioDefinitions), Equals(1))
+}
+
+func TestParsesDataTable(t *testing.T) {
+    f := parseString(t, `Feature:
+        Scenario:
+            Given these people
+                | name | email       |
+                | Bob  | bob\|bob.com |`)
+    table := f.ScenarioDefinitions[0].Definition().Steps[0].DataTable
+
+    AssertThat(t, len(table.Rows), Equals(2))
+    AssertThat(t, table.Rows[1].Values(), Equals([]string{"Bob", "bob|bob.com"}))
+    AssertThat(t, table.Rows[0].Cells[1].Location, Equals(Location{4, 26}))
+}
+
+func TestParsesScenarioOutlineWithExamples(t *testing.T) {
+    f := parseString(t, `Feature:
+        Scenario Outline: eating
+            Given <count> cukes
+        Examples: some
+            | count |
+            | 5     |
+            | 7     |`)
+    so, ok := f.ScenarioDefinitions[0].(*ScenarioOutline)
+
+    AssertThat(t, ok, IsTrue)
+    AssertThat(t, len(so.Examples), Equals(1))
+    AssertThat(t, so.Examples[0].Name, Equals("some"))
+    AssertThat(t, so.Examples[0].TableHeader.Values(), Equals([]string{"count"}))
+    AssertThat(t, len(so.Examples[0].TableBody), Equals(2))
+}
+
+func TestParsesRulesWithTheirOwnBackgrounds(t *testing.T) {
+    f := parseString(t, `Feature:
+    Background:
+        Given feature background
+    Scenario: outside
+        Given .
+    @billing
+    Rule: the rule
+        Background:
+            Given rule background
+        Scenario: inside
+            Given .`)
+
+    AssertThat(t, len(f.ScenarioDefinitions), Equals(1))
+    AssertThat(t, len(f.Rules), Equals(1))
+    AssertThat(t, f.Rules[0].Name, Equals("the rule"))
+    AssertThat(t, f.Rules[0].Tags[0].Name, Equals("@billing"))
+    AssertThat(t, f.Rules[0].Background.Steps[0].Text, Equals("rule background"))
+    AssertThat(t, f.Rules[0].ScenarioDefinitions[0].Definition().Name, Equals("inside"))
+}
+
+func TestRuleBackgroundMustComeFirst(t *testing.T) {
+    _, err := Parse(strings.NewReader(`Feature:
+    Rule:
+        Scenario:
+            Given .
+        Background:
+            Given .`))
+
+    AssertThat(t, err.(ParseErrors)[0].Location, Equals(Location{5, 9}))
+}
+
+func TestReturnsErrorForPlaceholderWithoutColumn(t *testing.T) {
+    _, err := Parse(strings.NewReader(`Feature:
+    Scenario Outline: <title>
+        Given <count> cukes
+            | <missing> |
+    Examples:
+        | count | title |
+        | 5     | five  |`))
+    errs := err.(ParseErrors)
+
+    AssertThat(t, len(errs), Equals(1))
+    AssertThat(t, errs[0].Location, Equals(Location{4, 15}))
+    AssertThat(t, errs[0].Message, Equals("placeholder <missing> has no matching column in the Examples at line 6"))
+}
+
+func TestOnlyChecksPlaceholdersWhichLookLikeIdentifiers(t *testing.T) {
+    f := parseString(t, `Feature:
+    Scenario Outline: comparing
+        Given 1 <2 and 3> 0 for <count>
+        And the request
+            """xml
+            <request><id><count></id></request>
+            """
+    Examples:
+        | count |
+        | 5     |`)
+
+    AssertThat(t, len(f.ScenarioDefinitions), Equals(1))
+}
+
+func TestReportsTheLineOfTheStepWithTheMissingPlaceholder(t *testing.T) {
+    _, err := Parse(strings.NewReader(`Feature:
+    Scenario Outline: comparing
+        Given <count> cukes
+        When I eat <eaten>
+    Examples:
+        | count |
+        | 5     |`))
+    errs := err.(ParseErrors)
+
+    AssertThat(t, len(errs), Equals(1))
+    AssertThat(t, errs[0].Location.Line, Equals(4))
+}
+
+func TestParsesTagsAndComments(t *testing.T) {
+    f := parseString(t, `@wip
+Feature:
+    # a comment
+    @fast @db
+    Scenario:
+        Given .`)
+
+    AssertThat(t, f.Tags[0].Name, Equals("@wip"))
+    AssertThat(t, f.ScenarioDefinitions[0].Definition().Tags[1].Name, Equals("@db"))
+    AssertThat(t, f.ScenarioDefinitions[0].Definition().Tags[1].Location, Equals(Location{4, 11}))
+    AssertThat(t, f.Comments[0].Text, Equals("# a comment"))
+}
+
+func TestParsesDocString(t *testing.T) {
+    f := parseString(t, `Feature:
+    Scenario:
+        Given a document
+            """
+            Some | text
+            """`)
+    ds := f.ScenarioDefinitions[0].Definition().Steps[0].DocString
+
+    AssertThat(t, ds.Delimiter, Equals(`"""`))
+    AssertThat(t, ds.Content, Equals("Some | text"))
+}
+
+func TestDocStringIndentationIsRelativeToDelimiter(t *testing.T) {
+    f := parseString(t, `Feature:
+    Scenario:
+        Given a document
+            """
+            first
+              second
+          third
+            """`)
+    ds := f.ScenarioDefinitions[0].Definition().Steps[0].DocString
+
+    AssertThat(t, ds.Content, Equals("first\n  second\nthird"))
+}
+
+func TestParsesDocStringContentType(t *testing.T) {
+    f := parseString(t, "Feature:\n  Scenario:\n    Given json\n      ```json\n      {}\n      ```")
+    ds := f.ScenarioDefinitions[0].Definition().Steps[0].DocString
+
+    AssertThat(t, ds.Delimiter, Equals("```"))
+    AssertThat(t, ds.ContentType, Equals("json"))
+    AssertThat(t, ds.Content, Equals("{}"))
+}
+
+func TestDocStringMayContainEscapedDelimiter(t *testing.T) {
+    f := parseString(t, `Feature:
+    Scenario:
+        Given a document
+            """
+            a \"\"\" b
+            """`)
+    ds := f.ScenarioDefinitions[0].Definition().Steps[0].DocString
+
+    AssertThat(t, ds.Content, Equals(`a """ b`))
+}
+
+func TestReturnsErrorForUnterminatedDocString(t *testing.T) {
+    _, err := Parse(strings.NewReader(`Feature:
+    Scenario:
+        Given a document
+            """
+            text`))
+
+    AssertThat(t, err.(ParseErrors)[0].Location, Equals(Location{4, 13}))
+}
+
+func TestReturnsErrorForInconsistentTable(t *testing.T) {
+    _, err := Parse(strings.NewReader(`Feature:
+        Scenario:
+            Given given
+                |name|addr|
+                |bob|`))
+
+    AssertThat(t, err != nil, IsTrue)
+}
+
+func TestCollectsAllParseErrorsWithPositions(t *testing.T) {
+    _, err := Parse(strings.NewReader(`Feature:
+    Given a step before any scenario
+    Scenario:
+        Given given
+            |name|addr|
+            |bob|
+        Examples:
+        Frobnicate: this`))
+    errs, ok := err.(ParseErrors)
+
+    AssertThat(t, ok, IsTrue)
+    AssertThat(t, len(errs), Equals(4))
+    AssertThat(t, errs[0].Location, Equals(Location{2, 5}))
+    AssertThat(t, errs[1].Location, Equals(Location{6, 13}))
+    AssertThat(t, errs[2].Message, Equals("Examples must follow a Scenario Outline"))
+    AssertThat(t, errs[3].Message, Equals(`unexpected text "Frobnicate: this"`))
+}
+
+func TestRejectsTagsBeforeSteps(t *testing.T) {
+    _, err := Parse(strings.NewReader(`Feature:
+    Scenario:
+        @tag
+        Given .`))
+
+    AssertThat(t, err.(ParseErrors)[0].Location, Equals(Location{3, 9}))
+}
+
+func TestParseErrorIncludesFileName(t *testing.T) {
+    e := &ParseError{"features/a.feature", Location{3, 5}, "oops"}
+
+    AssertThat(t, e.Error(), Equals("features/a.feature:3:5: oops"))
+}
+
+type errorCollector struct {
+    messages []string
+}
+
+func (c *errorCollector) Errorf(format string, args ...interface{}) {
+    c.messages = append(c.messages, fmt.Sprintf(format, args...))
+}
+
+func TestReportsEachParseErrorSeparately(t *testing.T) {
+    _, err := Parse(strings.NewReader(`Feature:
+    Given one
+    Given two`))
+    c := &errorCollector{}
+    reportParseErrors(c, err)
+
+    AssertThat(t, len(c.messages), Equals(2))
+}
+
+func TestExecuteDoesNotRunMalformedFeature(t *testing.T) {
+    g := createWriterlessRunner()
+    wasRun := false
+    g.RegisterStepDef(".", func(w *World) { wasRun = true })
+    g.Execute(`Feature:
+    Scenario:
+        Given .
+    Examples:`)
+
+    AssertThat(t, wasRun, IsFalse)
+}
+
+func TestExecuteFeatureRunsParsedFeature(t *testing.T) {
+    g := createWriterlessRunner()
+    wasCalled := false
+    g.RegisterStepDef("^the first setup$", func(w *World) { wasCalled = true })
+
+    g.ExecuteFeature(parseString(t, featureText))
+    AssertThat(t, wasCalled, IsTrue)
+}
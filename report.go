@@ -2,9 +2,35 @@ package gherkin
 
 type Report struct {
     scenarioCount int
+    skippedScenarios int
     pendingSteps int
     skippedSteps int
     passedSteps int
     failedSteps int
     undefinedSteps int
+    ambiguousSteps int
+    // Counted separately from the steps, since hooks are not steps.
+    hookFailures int
+    // The scenarios which neither passed nor were skipped, in order.
+    unsuccessful []*ScenarioInfo
+}
+
+// Adds the counts of another report to this one.
+func (rpt *Report) add(other Report) {
+    rpt.scenarioCount += other.scenarioCount
+    rpt.skippedScenarios += other.skippedScenarios
+    rpt.pendingSteps += other.pendingSteps
+    rpt.skippedSteps += other.skippedSteps
+    rpt.passedSteps += other.passedSteps
+    rpt.failedSteps += other.failedSteps
+    rpt.undefinedSteps += other.undefinedSteps
+    rpt.ambiguousSteps += other.ambiguousSteps
+    rpt.hookFailures += other.hookFailures
+    rpt.unsuccessful = append(rpt.unsuccessful, other.unsuccessful...)
+}
+
+func (rpt *Report) noteResult(info *ScenarioInfo) {
+    if info != nil && info.Result != nil && info.Result.Status != StepPassed && info.Result.Status != StepSkipped {
+        rpt.unsuccessful = append(rpt.unsuccessful, info)
+    }
 }
@@ -1,37 +1,57 @@
 package gherkin
 
 import (
+    "context"
     re "regexp"
     "strings"
     "fmt"
     "io"
-    "io/ioutil"
     "path/filepath"
     "os"
+    "reflect"
+    "runtime"
     matchers "github.com/tychofreeman/go-matchers"
 )
 
 type Runner struct {
     steps []stepdef
-    background Scenario
-    isExample bool
     setUp func()
     tearDown func()
-    currScenario Scenario
-    scenarios []Scenario
+    tagFilter tagExpression
+    language string
     output io.Writer
-}
-
-func (r *Runner) addStepLine(line, orig string) {
-    r.currScenario.AddStep(StepFromStringAndOrig(line, orig))
-}
-
-func (r *Runner) currStepLine() step {
-    l := r.currStep()
-    if l == nil {
-        return StepFromString("")
-    }
-    return *l
+    parameterTypes map[string]*parameterType
+    allowAmbiguous bool
+    registrationErrors []*RegistrationError
+    snippets snippets
+    snippetFile string
+    strictKeywords bool
+    worldFactory func() interface{}
+    // The context of the current run. Nil outside of RunContext().
+    ctx context.Context
+    hooks hooks
+    dryRun bool
+    strict bool
+    // Colour example rows by their result.
+    color bool
+}
+
+// What the runner passes down to each scenario and step it executes.
+type execution struct {
+    steps []stepdef
+    allowAmbiguous bool
+    // Collects a snippet for each undefined step, if not nil.
+    snippets *snippets
+    strictKeywords bool
+    worldFactory func() interface{}
+    // Cancelled if the run is. Each scenario starts with it as its context.
+    ctx context.Context
+    hooks *hooks
+    // Set if a BeforeScenario hook failed.
+    skipSteps bool
+    // Only match the steps, without calling anything.
+    dryRun bool
+    color bool
 }
 
 // Register a set-up function to be called at the beginning of each scenario
@@ -44,10 +64,44 @@ func (r *Runner) SetTearDownFn(tearDown func()) {
     r.tearDown = tearDown
 }
 
+// Register a function to make the state of each scenario, such as a
+// pointer to a struct of its own. Every step of the scenario, including
+// its Background steps, gets the same state from World.State(); the next
+// scenario gets a new one. If the factory panics, the scenario fails and
+// its steps are skipped.
+func (r *Runner) SetWorldFactory(factory func() interface{}) {
+    r.worldFactory = factory
+}
+
+// Only run scenarios whose tags match the Cucumber tag expression, for
+// example "@smoke and not (@slow or @wip)". The rest are reported as
+// skipped. An empty expression runs everything.
+func (r *Runner) SetTagFilter(expr string) error {
+    filter, err := parseTagExpression(expr)
+    if err != nil {
+        return err
+    }
+    r.tagFilter = filter
+    return nil
+}
+
+func (r *Runner) isFilteredOut(info *ScenarioInfo) bool {
+    return r.tagFilter != nil && !r.tagFilter.matches(info.Tags)
+}
+
+// The language of feature files which have no "# language:" comment,
+// given as in Cucumber's gherkin-languages.json, e.g. "de" or "pt".
+func (r *Runner) SetDefaultLanguage(language string) error {
+    if _, ok := dialects[language]; !ok {
+        return fmt.Errorf("unknown language %q", language)
+    }
+    r.language = language
+    return nil
+}
+
 // The recommended way to create a gherkin.Runner object.
 func CreateRunner() *Runner {
-    s := []Scenario{}
-    return &Runner{[]stepdef{}, nil, false, nil, nil, nil, s, os.Stdout}
+    return &Runner{steps: []stepdef{}, language: defaultLanguage, output: os.Stdout, parameterTypes: copyParameterTypes(builtinParameterTypes), color: isTerminal(os.Stdout)}
 }
 
 func createWriterlessRunner() *Runner {
@@ -57,74 +111,145 @@ func createWriterlessRunner() *Runner {
 }
 
 // Register a step definition. This requires a regular expression
-// pattern and a function to execute.
-func (r *Runner) RegisterStepDef(pattern string, f func(*World)) {
-    r.steps = append(r.steps, createstepdef(pattern, f))
+// pattern and a function to execute. The function either takes just a
+// *World, or takes an argument for each capture group (optionally after
+// a *World) such as func(w *World, count int, name string). Captures are
+// converted to string, bool, int, uint and float types and time.Duration.
+// An invalid pattern or function is reported when the runner is Run().
+func (r *Runner) RegisterStepDef(pattern string, f interface{}) {
+    r.registerFor("", pattern, f)
 }
 
-func (r *Runner) callSetUp() {
-    if r.setUp != nil {
-        r.setUp()
-    }
+// Register a step definition for Given steps (and the And and But steps
+// which follow them). This only differs from RegisterStepDef() if the
+// runner matches keywords strictly.
+func (r *Runner) Given(pattern string, f interface{}) {
+    r.registerFor(ContextKeyword, pattern, f)
 }
 
-func (r *Runner) callTearDown() {
-    if r.tearDown != nil {
-        r.tearDown()
-    }
+// Register a step definition for When steps. See Given().
+func (r *Runner) When(pattern string, f interface{}) {
+    r.registerFor(ActionKeyword, pattern, f)
 }
 
-func (r *Runner) runBackground() {
-    if r.background != nil {
-        r.background.Execute(r.steps, r.output)
-    }
+// Register a step definition for Then steps. See Given().
+func (r *Runner) Then(pattern string, f interface{}) {
+    r.registerFor(OutcomeKeyword, pattern, f)
 }
 
-func parseAsStep(line string) (bool, string) {
-    givenMatch, _ := re.Compile(`^\s*(Given|When|Then|And|But|\*)\s+(.*?)\s*$`)
-    if s := givenMatch.FindStringSubmatch(line); s != nil && len(s) > 1 {
-        return true, s[2]
-    }
-    return false, ""
+func (r *Runner) registerFor(kt KeywordType, pattern string, f interface{}) {
+    s, err := createstepdef(pattern, f, r.parameterTypes)
+    s.keywordType = kt
+    r.addStepDef(pattern, s, err)
 }
 
-func isScenarioOutline(line string) bool {
-    return lineMatches(`^\s*Scenario Outline:\s*(.*?)\s*$`, line)
+// Only match step definitions registered with Given(), When() or Then()
+// against steps of that type, so that the same phrasing may be defined
+// differently for each. And and But steps take the type of the step
+// before them. Step definitions registered with RegisterStepDef() match
+// any step.
+func (r *Runner) SetStrictKeywords(strict bool) {
+    r.strictKeywords = strict
 }
 
-func isExampleLine(line string) bool {
-    return lineMatches(`^\s*Examples:\s*(.*?)\s*$`, line)
+// Register a step definition given as a Cucumber Expression, such as
+// "I have {int} cucumber(s) in my belly/stomach". RegisterStepDef() only
+// treats a pattern as an expression if it uses a parameter type.
+func (r *Runner) RegisterExpression(expr string, f interface{}) {
+    s, err := createexpressionstepdef(expr, f, r.parameterTypes)
+    r.addStepDef(expr, s, err)
 }
 
-func isScenarioLine(line string) (bool) {
-    return lineMatches(`^\s*Scenario:\s*(.*?)\s*$`, line)
+// A step definition, or hook, which could not be registered, and where it
+// was registered, as file:line.
+type RegistrationError struct {
+    // "step definition" or "hook".
+    Kind string
+    // The step definition's pattern, or the hook's tag expression.
+    Pattern string
+    Location string
+    Err error
 }
 
-func isFeatureLine(line string) bool {
-    return lineMatches(`Feature:\s*(.*?)\s*$`, line)
+func (e *RegistrationError) Error() string {
+    return fmt.Sprintf("%s: cannot register %s %q: %v", e.Location, e.Kind, e.Pattern, e.Err)
 }
-func isBackgroundLine(line string) bool {
-    return lineMatches(`^\s*Background:`, line)
+
+func (r *Runner) addStepDef(pattern string, s stepdef, err error) {
+    location := callerLocation()
+    if err != nil {
+        r.registrationErrors = append(r.registrationErrors, &RegistrationError{"step definition", pattern, location, err})
+        return
+    }
+    s.location = location
+    r.steps = append(r.steps, s)
+}
+
+// The file:line of the code outside this package which is registering
+// a step definition, whether directly or through gherkin.Given() etc.
+func callerLocation() string {
+    pcs := make([]uintptr, 32)
+    frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+    for {
+        frame, more := frames.Next()
+        inPackage := strings.HasPrefix(frame.Function, packagePath + ".")
+        if !inPackage || strings.HasSuffix(frame.File, "_test.go") {
+            return fmt.Sprintf("%s:%d", filepath.Base(frame.File), frame.Line)
+        }
+        if !more {
+            return ""
+        }
+    }
 }
 
-func lineMatches(spec, line string) bool {
-    featureMatch, _ := re.Compile(spec)
-    if s := featureMatch.FindStringSubmatch(line); s != nil {
-        return true
+var packagePath = reflect.TypeOf(Runner{}).PkgPath()
+
+// Use the first matching step definition when more than one matches a
+// step, as older versions did, rather than failing the step as ambiguous.
+func (r *Runner) AllowAmbiguousFirstMatch() {
+    r.allowAmbiguous = true
+}
+
+// Define a parameter type for a domain type, such as
+//
+//     r.DefineParameterType("color", "red|green|blue", func(s string) (Color, error) {...})
+//
+// Expressions may then use {color}, and step functions may take a Color
+// argument for any capture, whether their pattern is an expression or a
+// regular expression. Regular expression captures for Go's own types,
+// such as string or int, are never transformed. The transformer is either
+// func(string) (T, error) or func(string) T; an error it returns fails
+// the step. Define parameter types before the step definitions which use
+// them.
+func (r *Runner) DefineParameterType(name, regexp string, transformer interface{}) error {
+    if _, ok := r.parameterTypes[name]; ok {
+        return fmt.Errorf("parameter type {%s} is already defined", name)
     }
-    return false
+    pt, err := newParameterType(name, regexp, transformer)
+    if err != nil {
+        return err
+    }
+    r.parameterTypes[name] = pt
+    return nil
 }
 
-func parseTableLine(line string) (fields []string) {
-    mlMatch, _ := re.Compile(`^\s*\|.*\|\s*$`)
-    if mlMatch.MatchString(line) {
-        tmpFields := strings.Split(line, "|")
-        fields = tmpFields[1:len(tmpFields)-1]
-        for i, f := range fields {
-            fields[i] = strings.TrimSpace(f)
+// The set-up and tear-down functions fail like hooks do, by panicking.
+func (r *Runner) callSetUp() []error {
+    if r.setUp != nil {
+        if err := callHook("SetUp", r.setUp, r.output); err != nil {
+            return []error{err}
         }
     }
-    return
+    return nil
+}
+
+func (r *Runner) callTearDown() []error {
+    if r.tearDown != nil {
+        if err := callHook("TearDown", r.tearDown, r.output); err != nil {
+            return []error{err}
+        }
+    }
+    return nil
 }
 
 func createTableMap(keys []string, fields []string) (l map[string]string) {
@@ -135,125 +260,277 @@ func createTableMap(keys []string, fields []string) (l map[string]string) {
     return
 }
 
-func (r *Runner) resetWithScenario(s Scenario) {
-    r.isExample = false
-    r.scenarios = append(r.scenarios, s)
-    r.currScenario = r.scenarios[len(r.scenarios)-1]
+func printableLines(indent, text string) []executable {
+    lines := []executable{}
+    if len(text) > 0 {
+        for _, l := range strings.Split(text, "\n") {
+            lines = append(lines, &printable_line{indent + l})
+        }
+    }
+    return lines
 }
 
-func (r *Runner) startScenarioOutline() {
-    r.resetWithScenario(&scenario_outline{})
+// What a scenario inherits from the Feature, and Rule, enclosing it.
+type ancestry struct {
+    tags [][]*Tag
+    backgrounds []*scenario
+    rule string
 }
 
-func (r *Runner) startBackground(orig string) {
-    r.resetWithScenario(&scenario{orig: orig, isBackground: true})
+func (a ancestry) child(tags []*Tag, bg *Background) ancestry {
+    child := ancestry{rule: a.rule}
+    child.tags = append(append(child.tags, a.tags...), tags)
+    child.backgrounds = append(child.backgrounds, a.backgrounds...)
+    if bg != nil {
+        child.backgrounds = append(child.backgrounds, backgroundFromAST(bg))
+    }
+    return child
 }
 
-func (r *Runner) startScenario(orig string) {
-    r.resetWithScenario(&scenario{orig: orig})
+func (a ancestry) scenarioInfo(name string, loc Location, tags ...[]*Tag) *ScenarioInfo {
+    return &ScenarioInfo{Name: name, Location: loc, Rule: a.rule, Tags: inheritTags(append(a.tags, tags...)...)}
 }
 
-func (r *Runner) currStep() *step {
-    if r.currScenario != nil {
-        return r.currScenario.Last()
+// Turns the syntax tree into the list of things to execute, in order.
+// Each scenario runs the backgrounds of its Feature and Rule itself.
+func (r *Runner) load(f *Feature) []executable {
+    scenarios := []executable{}
+    if f == nil {
+        return scenarios
     }
-    return nil
+    scenarios = append(scenarios, &printable_line{f.Keyword + ": " + f.Name})
+    scenarios = append(scenarios, printableLines("  ", f.Description)...)
+    parent := ancestry{}.child(f.Tags, f.Background)
+    scenarios = append(scenarios, r.loadDefinitions(f.ScenarioDefinitions, parent)...)
+    for _, rule := range f.Rules {
+        scenarios = append(scenarios, &printable_line{"  " + rule.Keyword + ": " + rule.Name})
+        scenarios = append(scenarios, printableLines("    ", rule.Description)...)
+        ruleParent := parent.child(rule.Tags, rule.Background)
+        ruleParent.rule = rule.Name
+        scenarios = append(scenarios, r.loadDefinitions(rule.ScenarioDefinitions, ruleParent)...)
+    }
+    return scenarios
 }
 
-
-func (r *Runner) setMlKeys(data []string) {
-    r.currStep().setMlKeys(data)
-}
-
-func (r *Runner) addMlStep(data map[string]string) {
-    r.currStep().addMlData(data)
-}
-func (r *Runner) addPrintableLine(line string) {
-    r.scenarios = append(r.scenarios, &printable_line{line})
-}
-
-func (r *Runner) step(line string) {
-    fields := parseTableLine(line)
-    isStep, data := parseAsStep(line)
-    if r.currScenario != nil && isStep {
-        r.addStepLine(data, line)
-    } else if isScenarioOutline(line) {
-        r.startScenarioOutline()
-    } else if isScenarioLine(line) {
-        r.startScenario(line)
-    } else if isFeatureLine(line) {
-        r.addPrintableLine(line)
-    } else if isBackgroundLine(line) {
-        r.startBackground(line)
-        r.background = r.currScenario
-    } else if isExampleLine(line) {
-        r.addPrintableLine(line)
-        r.isExample = true
-    } else if r.isExample && len(fields) > 0 {
-        r.addPrintableLine(line)
-        switch scen := r.currScenario.(type) {
-            case *scenario_outline:
-                if scen.keys == nil {
-                    scen.keys = fields
-                } else {
-                    newScenario := scen.CreateForExample(createTableMap(scen.keys, fields))
-                    r.scenarios = append(r.scenarios, &newScenario)
+// Names a scenario generated from an outline after the outline and its
+// example row, e.g. "eating (start: 12, eat: 5)".
+func exampleName(name string, keys, values []string) string {
+    pairs := make([]string, len(keys))
+    for i, k := range keys {
+        pairs[i] = k + ": " + values[i]
+    }
+    return strings.TrimSpace(name + " (" + strings.Join(pairs, ", ") + ")")
+}
+
+func (r *Runner) loadDefinitions(definitions []Definition, parent ancestry) []executable {
+    scenarios := []executable{}
+    for _, d := range definitions {
+        switch def := d.(type) {
+        case *Scenario:
+            s := scenarioFromAST(def)
+            s.backgrounds = parent.backgrounds
+            s.info = parent.scenarioInfo(def.Name, def.Location, def.Tags)
+            if r.isFilteredOut(s.info) {
+                scenarios = append(scenarios, &filtered_scenario{})
+            } else {
+                scenarios = append(scenarios, s)
+            }
+        case *ScenarioOutline:
+            outline := outlineFromAST(def)
+            scenarios = append(scenarios, outline)
+            // Each Examples block has its own header, so keys are never
+            // shared between them.
+            for _, ex := range def.Examples {
+                if len(ex.Tags) > 0 {
+                    scenarios = append(scenarios, &printable_line{"    " + strings.Join(inheritTags(ex.Tags), " ")})
                 }
-            default:
-        }
-    } else if r.currStep() != nil && len(fields) > 0 {
-        r.addPrintableLine(line)
-        s := *r.currStep()
-        if len(s.keys) == 0 {
-            r.setMlKeys(fields)
-        } else if len(fields) != len(s.keys) {
-            panic(fmt.Sprintf("Wrong number of fields in multi-line step [%v] - expected %d fields but found %d", line, len(s.keys), len(fields)))
-        } else if len(fields) > 0 {
-            l := createTableMap(s.keys, fields)
-            r.addMlStep(l)
+                scenarios = append(scenarios, &printable_line{"    " + ex.Keyword + ": " + ex.Name})
+                scenarios = append(scenarios, printableLines("      ", ex.Description)...)
+                if ex.TableHeader == nil {
+                    continue
+                }
+                keys := ex.TableHeader.Values()
+                scenarios = append(scenarios, &printable_line{formatTableRow("      ", ex.TableHeader)})
+                for _, row := range ex.TableBody {
+                    example := createTableMap(keys, row.Values())
+                    newScenario := outline.CreateForExample(example)
+                    newScenario.backgrounds = parent.backgrounds
+                    newScenario.row = formatTableRow("      ", row)
+                    name := placeholderReplacer(example).Replace(def.Name)
+                    newScenario.orig = "  " + def.Keyword + ": " + name
+                    newScenario.info = parent.scenarioInfo(exampleName(name, keys, row.Values()), row.Location, def.Tags, ex.Tags)
+                    if r.isFilteredOut(newScenario.info) {
+                        scenarios = append(scenarios, &filtered_scenario{})
+                    } else {
+                        scenarios = append(scenarios, &newScenario)
+                    }
+                }
+            }
         }
-    } else {
-        r.addPrintableLine(line)
     }
+    return scenarios
+}
+
+func (r *Runner) execution() *execution {
+    x := &execution{
+        steps: r.steps,
+        allowAmbiguous: r.allowAmbiguous,
+        snippets: &r.snippets,
+        strictKeywords: r.strictKeywords,
+        worldFactory: r.worldFactory,
+        ctx: r.ctx,
+        hooks: &r.hooks,
+        dryRun: r.dryRun,
+        color: r.color,
+    }
+    if r.dryRun {
+        x.worldFactory = nil
+        x.hooks = nil
+    }
+    return x
+}
+
+// Parse the features and match their steps, reporting undefined and
+// ambiguous steps and printing snippets, but without calling any step
+// definition, hook, set-up or tear-down function. Steps which would have
+// been run are reported as skipped.
+func (r *Runner) SetDryRun(dryRun bool) {
+    r.dryRun = dryRun
 }
 
-func (r *Runner) executeScenario(scenario Scenario) Report{
+func scenarioInfoOf(e executable) *ScenarioInfo {
+    if s, ok := e.(*scenario); ok {
+        return s.info
+    }
+    return nil
+}
+
+// Scenario hooks run inside the set-up and tear-down functions, with
+// the Around hooks wrapping the Before hooks, steps and After hooks.
+// If the set-up function or a Before hook fails, the steps are skipped,
+// but the After hooks and tear-down function are always run. Their
+// failures are added to the scenario's result. If an Around hook does not
+// call run, the scenario and its steps are skipped.
+func (r *Runner) executeScenario(scenario executable) Report {
+    x := r.execution()
+    if scenario.IsJustPrintable() {
+        return scenario.Execute(x, r.output)
+    }
+    info := scenarioInfoOf(scenario)
+    result := &ScenarioResult{}
+    if info != nil {
+        info.Result = result
+        info.Context = r.Context()
+    }
+    if r.dryRun {
+        rpt := scenario.Execute(x, r.output)
+        result.Status = scenarioStatus(rpt)
+        rpt.noteResult(info)
+        return rpt
+    }
     rpt := Report{}
-    if !scenario.IsBackground() {
-        if !scenario.IsJustPrintable() {
-            r.callSetUp()
-            r.runBackground()
+    hookErrors := r.callSetUp()
+    result.Errors = append(result.Errors, hookErrors...)
+    ran := false
+    aroundErrors := r.hooks.callAroundScenario(info, func() {
+        ran = true
+        beforeErrors := r.hooks.callBeforeScenario(info, r.output)
+        hookErrors = append(hookErrors, beforeErrors...)
+        result.Errors = append(result.Errors, beforeErrors...)
+        x.skipSteps = len(hookErrors) > 0
+        var err error
+        rpt, err = executeRecovered(scenario, x, r.output)
+        if err != nil {
+            hookErrors = append(hookErrors, err)
+            result.Errors = append(result.Errors, err)
         }
-        rpt = scenario.Execute(r.steps, r.output)
-        if !scenario.IsJustPrintable() {
-            r.callTearDown()
+        result.Status = scenarioStatus(rpt)
+        if len(hookErrors) > 0 {
+            result.Status = StepFailed
+        }
+        afterErrors := r.hooks.callAfterScenario(info, r.output)
+        hookErrors = append(hookErrors, afterErrors...)
+        result.Errors = append(result.Errors, afterErrors...)
+    }, r.output)
+    if !ran {
+        x.skipSteps, x.worldFactory, x.hooks = true, nil, nil
+        rpt = scenario.Execute(x, r.output)
+        result.Status = StepSkipped
+        if len(aroundErrors) == 0 {
+            rpt.skippedScenarios++
         }
     }
+    hookErrors = append(hookErrors, aroundErrors...)
+    result.Errors = append(result.Errors, aroundErrors...)
+    tearDownErrors := r.callTearDown()
+    hookErrors = append(hookErrors, tearDownErrors...)
+    result.Errors = append(result.Errors, tearDownErrors...)
+    rpt.hookFailures += len(hookErrors)
+    if len(hookErrors) > 0 {
+        result.Status = StepFailed
+    }
+    rpt.noteResult(info)
     return rpt
 }
 
-func (r *Runner) executeScenarios(scenarios []Scenario) Report {
+// Steps and hooks recover their own panics. Anything else which panics
+// while the scenario runs fails it, rather than skipping its After hooks
+// and tear-down function.
+func executeRecovered(scenario executable, x *execution, output io.Writer) (rpt Report, err error) {
+    defer func() {
+        if rec := recover(); rec != nil {
+            err = fmt.Errorf("scenario panicked: %v", rec)
+            if output != nil {
+                fmt.Fprintf(output, "PANIC - %v\n", rec)
+            }
+        }
+    }()
+    return scenario.Execute(x, output), nil
+}
+
+func (r *Runner) executeScenarios(scenarios []executable) Report {
     rpt := Report{}
     for _, scenario := range scenarios {
-        scenarioRpt := r.executeScenario(scenario)
-        rpt.scenarioCount++
-        rpt.skippedSteps += scenarioRpt.skippedSteps
-        rpt.pendingSteps += scenarioRpt.pendingSteps
-        rpt.passedSteps += scenarioRpt.passedSteps
-        rpt.failedSteps += scenarioRpt.failedSteps
-        rpt.undefinedSteps += scenarioRpt.undefinedSteps
+        if !scenario.IsJustPrintable() {
+            rpt.scenarioCount++
+        }
+        rpt.add(r.executeScenario(scenario))
     }
     return rpt
 }
 
 // Once the step definitions are Register()'d, use Execute() to
-// parse and execute Gherkin data.
+// parse and execute Gherkin data. Nothing is executed if the data
+// cannot be parsed, or if a step definition could not be registered;
+// the errors are written to the output instead.
 func (r *Runner) Execute(file string) Report {
-    lines := strings.Split(file, "\n")
-    for _, line := range lines {
-        r.step(line)
+    if len(r.registrationErrors) > 0 {
+        if r.output != nil {
+            for _, e := range r.registrationErrors {
+                fmt.Fprintf(r.output, "%v\n", e)
+            }
+        }
+        return Report{}
+    }
+    feature, err := parse("", strings.NewReader(file), r.language)
+    if err != nil {
+        if r.output != nil {
+            fmt.Fprintf(r.output, "%v\n", err)
+        }
+        return Report{}
     }
-    return r.executeScenarios(r.scenarios)
+    return r.ExecuteFeature(feature)
+}
+
+// Executes a Feature which has already been Parse()'d.
+func (r *Runner) ExecuteFeature(f *Feature) Report {
+    if r.dryRun {
+        return r.executeScenarios(r.load(f))
+    }
+    hookFailures := r.hooks.callBeforeFeature(f, r.output)
+    rpt := r.executeScenarios(r.load(f))
+    rpt.hookFailures += hookFailures + r.hooks.callAfterFeature(f, r.output)
+    return rpt
 }
 
 func generateStepReport(count int, name string) string {
@@ -278,43 +555,189 @@ func PrintReport(rpt Report, output io.Writer) {
     stepSpecifics = addCount(stepSpecifics, rpt.failedSteps, "failed")
     stepSpecifics = addCount(stepSpecifics, rpt.pendingSteps, "pending")
     stepSpecifics = addCount(stepSpecifics, rpt.undefinedSteps, "undefined")
+    stepSpecifics = addCount(stepSpecifics, rpt.ambiguousSteps, "ambiguous")
     subset := strings.Join(stepSpecifics, ", ")
     if len(subset) > 0 {
         subset = "(" + subset + ")"
     }
 
-    totalSteps := rpt.skippedSteps + rpt.passedSteps + rpt.failedSteps + rpt.pendingSteps + rpt.undefinedSteps
-    fmt.Fprintf(output, "%d scenarios\n%d steps%s\n", rpt.scenarioCount, totalSteps, subset)
+    totalSteps := rpt.skippedSteps + rpt.passedSteps + rpt.failedSteps + rpt.pendingSteps + rpt.undefinedSteps + rpt.ambiguousSteps
+    skipped := ""
+    if rpt.skippedScenarios > 0 {
+        skipped = fmt.Sprintf("(%d skipped)", rpt.skippedScenarios)
+    }
+    fmt.Fprintf(output, "%d scenarios%s\n%d steps%s\n", rpt.scenarioCount, skipped, totalSteps, subset)
+    if rpt.hookFailures > 0 {
+        fmt.Fprintf(output, "%d hooks failed\n", rpt.hookFailures)
+    }
+}
+
+// Each ParseError is reported separately, so that the test output
+// lists every problem in the file.
+func reportParseErrors(t matchers.Errorable, err error) {
+    if errs, ok := err.(ParseErrors); ok {
+        for _, e := range errs {
+            t.Errorf("%v", e)
+        }
+    } else {
+        t.Errorf("%v", err)
+    }
+}
+
+// In strict mode, undefined and pending steps fail the run, as well as
+// failed and ambiguous steps. Otherwise they are only reported.
+func (r *Runner) SetStrict(strict bool) {
+    r.strict = strict
+}
+
+func (r *Runner) isFailure(status StepStatus) bool {
+    switch status {
+    case StepFailed, StepAmbiguous:
+        return true
+    case StepUndefined, StepPending:
+        return r.strict
+    }
+    return false
+}
+
+func (r *Runner) failed(rpt Report) bool {
+    if rpt.failedSteps > 0 || rpt.ambiguousSteps > 0 || rpt.hookFailures > 0 {
+        return true
+    }
+    return r.strict && (rpt.undefinedSteps > 0 || rpt.pendingSteps > 0)
+}
+
+// Lists each scenario which failed the run, e.g.
+//
+//     Failed features/basket.feature:
+//         features/basket.feature:12: eating apples (undefined)
+func (r *Runner) failureMessage(path string, rpt Report) string {
+    msg := "Failed " + path
+    lines := []string{}
+    for _, info := range rpt.unsuccessful {
+        if r.isFailure(info.Result.Status) {
+            lines = append(lines, fmt.Sprintf("\t%s:%d: %s (%s)", path, info.Location.Line, info.Name, info.Result.Status))
+        }
+    }
+    if len(lines) > 0 {
+        msg += ":\n" + strings.Join(lines, "\n")
+    }
+    return msg
 }
 
 // Once the step definitions are Register()'d, use Run() to
 // locate all *.feature files within the feature/ subdirectory
-// of the current directory.
+// of the current directory. If any step definition could not be
+// registered, each one is reported and no features are run.
 func (r *Runner) Run(t matchers.Errorable) {
+    r.RunContext(context.Background(), t)
+}
+
+// The context of the current run, for set-up and tear-down functions and
+// suite and feature hooks. Scenario and step hooks should use the context
+// in their ScenarioInfo or StepInfo, which also has the values set by
+// steps. Outside of RunContext(), this is context.Background().
+func (r *Runner) Context() context.Context {
+    if r.ctx == nil {
+        return context.Background()
+    }
+    return r.ctx
+}
+
+// Like Run(), but each scenario starts with ctx as its context. If ctx is
+// cancelled, the remaining steps are skipped and the run fails.
+func (r *Runner) RunContext(ctx context.Context, t matchers.Errorable) {
+    r.ctx = ctx
+    defer func() { r.ctx = nil }()
+    if len(r.registrationErrors) > 0 {
+        for _, e := range r.registrationErrors {
+            t.Errorf("%v", e)
+        }
+        return
+    }
+    if !r.dryRun {
+        if failures := r.hooks.callBeforeSuite(r.output); failures > 0 {
+            t.Errorf("%d BeforeSuite hooks failed", failures)
+        }
+    }
     featureMatch, _ := re.Compile(`.*\.feature`)
     filepath.Walk("features", func(walkPath string, info os.FileInfo, err error) error {
         if err != nil {
             return err
         }
+        if ctx.Err() != nil {
+            return ctx.Err()
+        }
         if info.Name() != "features" && info.IsDir() {
             return filepath.SkipDir
         } else if !info.IsDir() && featureMatch.MatchString(info.Name()) {
-            file, _ := os.Open(walkPath)
-            data, _ := ioutil.ReadAll(file)
-            rpt := r.Execute(string(data))
-            PrintReport(rpt, r.output)
-            r.scenarios = []Scenario{}
-            r.background = nil
-            if rpt.failedSteps > 0 {
-                t.Errorf("Failed %s", file)
+            feature, err := parseFile(walkPath, r.language)
+            if err != nil {
+                reportParseErrors(t, err)
+                return nil
+            }
+            rpt := r.ExecuteFeature(feature)
+            if r.output != nil {
+                PrintReport(rpt, r.output)
+            }
+            if r.failed(rpt) {
+                t.Errorf("%s", r.failureMessage(walkPath, rpt))
             }
         }
         return nil
     })
+    if !r.dryRun {
+        if failures := r.hooks.callAfterSuite(r.output); failures > 0 {
+            t.Errorf("%d AfterSuite hooks failed", failures)
+        }
+    }
+    if ctx.Err() != nil {
+        t.Errorf("run stopped: %v", ctx.Err())
+    }
+    if err := r.reportSnippets(); err != nil {
+        t.Errorf("%v", err)
+    }
+}
+
+// Also write the snippets for undefined steps to this file, replacing
+// anything it contains, at the end of Run().
+func (r *Runner) SetSnippetFile(path string) {
+    r.snippetFile = path
+}
+
+// Prints the snippet of each undefined step found so far.
+func (r *Runner) reportSnippets() error {
+    if len(r.snippets.list) == 0 {
+        return nil
+    }
+    if r.output != nil {
+        fmt.Fprintf(r.output, "\nYou can implement step definitions for undefined steps with these snippets:\n\n%s", &r.snippets)
+    }
+    if len(r.snippetFile) > 0 {
+        return os.WriteFile(r.snippetFile, []byte(r.snippets.String()), 0644)
+    }
+    return nil
 }
 
 // By default, Runner uses os.Stdout to write to. However, it may be useful
-// to redirect. To do so, provide an io.Writer here.
+// to redirect. To do so, provide an io.Writer here. Example rows are only
+// coloured if w is a terminal, unless SetColor() is called afterwards.
 func (r *Runner) SetOutput(w io.Writer) {
     r.output = w
+    r.color = isTerminal(w)
+}
+
+// Turn the colouring of example rows by their result on or off,
+// whatever the output is.
+func (r *Runner) SetColor(color bool) {
+    r.color = color
+}
+
+func isTerminal(w io.Writer) bool {
+    f, ok := w.(*os.File)
+    if !ok {
+        return false
+    }
+    stat, err := f.Stat()
+    return err == nil && stat.Mode()&os.ModeCharDevice != 0
 }
@@ -4,6 +4,9 @@ import (
     "testing"
     . "github.com/tychofreeman/go-matchers"
     "io"
+    "os"
+    "path/filepath"
+    "strings"
 )
 
 type MockScenario struct {
@@ -14,7 +17,7 @@ func (ms MockScenario) AddStep(s step) {
 func (ms MockScenario) Last() *step {
     return nil
 }
-func (ms MockScenario) Execute([]stepdef, io.Writer) Report {
+func (ms MockScenario) Execute(*execution, io.Writer) Report {
     return ms.rpt
 }
 func (ms MockScenario) IsBackground() bool {
@@ -25,9 +28,26 @@ func (ms MockScenario) IsJustPrintable() bool {
     return false
 }
 
+type PanickingScenario struct {
+    MockScenario
+}
+func (ps PanickingScenario) Execute(*execution, io.Writer) Report {
+    panic("boom")
+}
+
+func TestScenarioWhichPanicsStillRunsTheTearDown(t *testing.T) {
+    tornDown := false
+    r := createWriterlessRunner()
+    r.SetTearDownFn(func() { tornDown = true })
+    rpt := r.executeScenarios([]executable{PanickingScenario{}})
+
+    AssertThat(t, tornDown, IsTrue)
+    AssertThat(t, rpt.hookFailures, Equals(1))
+}
+
 func TestReportsNumberOfScenarios(t *testing.T) {
-    scenarios := []Scenario{
-        MockScenario{rpt:Report{0,0,0,1,0,0}},
+    scenarios := []executable{
+        MockScenario{rpt:Report{passedSteps:1}},
     }
 
     r := createWriterlessRunner()
@@ -37,8 +57,8 @@ func TestReportsNumberOfScenarios(t *testing.T) {
 }
 
 func TestReportsNumberOfStepsInScenarios(t *testing.T) {
-    scenarios := []Scenario{
-        MockScenario{rpt:Report{0,2,2,2,2,2}},
+    scenarios := []executable{
+        MockScenario{rpt:Report{pendingSteps:2, skippedSteps:2, passedSteps:2, failedSteps:2, undefinedSteps:2}},
     }
 
     r := createWriterlessRunner()
@@ -49,3 +69,59 @@ func TestReportsNumberOfStepsInScenarios(t *testing.T) {
     AssertThat(t, rpt.passedSteps, Equals(2))
     AssertThat(t, rpt.failedSteps, Equals(2))
 }
+
+const unfinishedFeature = `Feature: unfinished
+    Scenario: passes
+        Given it works
+    Scenario: undefined
+        Given it is undefined
+    Scenario: pending
+        Given it is pending
+`
+
+func unfinishedRunner() *Runner {
+    g := createWriterlessRunner()
+    g.RegisterStepDef("^it works$", func(w *World) { })
+    g.RegisterStepDef("^it is pending$", func(w *World) { Pending() })
+    return g
+}
+
+func TestUndefinedAndPendingStepsOnlyFailInStrictMode(t *testing.T) {
+    g := unfinishedRunner()
+    rpt := g.Execute(unfinishedFeature)
+
+    AssertThat(t, g.failed(rpt), IsFalse)
+    g.SetStrict(true)
+    AssertThat(t, g.failed(rpt), IsTrue)
+}
+
+func TestStrictFailureListsTheOffendingScenarios(t *testing.T) {
+    g := unfinishedRunner()
+    g.SetStrict(true)
+    rpt := g.Execute(unfinishedFeature)
+
+    AssertThat(t, g.failureMessage("features/unfinished.feature", rpt), Equals(
+        "Failed features/unfinished.feature:\n" +
+        "\tfeatures/unfinished.feature:4: undefined (undefined)\n" +
+        "\tfeatures/unfinished.feature:6: pending (pending)"))
+}
+
+func TestRunFailsOnUndefinedStepsInStrictMode(t *testing.T) {
+    dir := t.TempDir()
+    os.Mkdir(filepath.Join(dir, "features"), 0755)
+    os.WriteFile(filepath.Join(dir, "features", "unfinished.feature"), []byte(unfinishedFeature), 0644)
+    wd, _ := os.Getwd()
+    os.Chdir(dir)
+    defer os.Chdir(wd)
+
+    lenient := &errorCollector{}
+    unfinishedRunner().Run(lenient)
+    strict := &errorCollector{}
+    g := unfinishedRunner()
+    g.SetStrict(true)
+    g.Run(strict)
+
+    AssertThat(t, len(lenient.messages), Equals(0))
+    AssertThat(t, len(strict.messages), Equals(1))
+    AssertThat(t, strings.Contains(strict.messages[0], filepath.Join("features", "unfinished.feature") + ":4: undefined (undefined)"), IsTrue)
+}
@@ -1,35 +1,51 @@
 package gherkin
 
 import (
+    "bytes"
+    "errors"
     "fmt"
     "io"
-    re "regexp"
+    "sort"
+    "strings"
 )
 
 type scenario_outline struct {
     steps []step
-    keys []string
     isPending bool
+    orig string
 }
 
-func ScenarioOutline() scenario_outline {
-    return scenario_outline{}
+func outlineFromAST(so *ScenarioOutline) *scenario_outline {
+    outline := &scenario_outline{orig: "  " + so.Keyword + ": " + so.Name}
+    for _, s := range stepsFromAST(so.Steps) {
+        outline.AddStep(s)
+    }
+    return outline
 }
 
 func (so *scenario_outline) AddStep(s step) {
     so.steps = append(so.steps, s)
 }
 
+// Replaces each <key> with its value from the example row.
+func placeholderReplacer(example map[string]string) *strings.Replacer {
+    keys := []string{}
+    for k := range example {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    pairs := []string{}
+    for _, k := range keys {
+        pairs = append(pairs, "<" + k + ">", example[k])
+    }
+    return strings.NewReplacer(pairs...)
+}
+
 func (so scenario_outline) CreateForExample(example map[string]string) scenario {
     s := scenario{}
+    replacer := placeholderReplacer(example)
     for _, currStep := range so.steps {
-        l := currStep.line
-
-        for k, v := range example {
-            r, _ := re.Compile("<" + k + ">")
-            l = r.ReplaceAllString(l, v)
-        }
-        s.steps = append(s.steps, StepFromString(l))
+        s.steps = append(s.steps, currStep.withExample(replacer))
     }
 
     return s
@@ -42,11 +58,17 @@ func (so *scenario_outline) Last() *step {
     return nil
 }
 
-func (so *scenario_outline) IsBackground() bool { return false }
+// The outline itself is never run, only the scenarios generated from it.
+func (scen *scenario_outline) IsJustPrintable() bool { return true }
 
-func (scen *scenario_outline) IsJustPrintable() bool { return false }
-
-func (so *scenario_outline) Execute(s []stepdef, output io.Writer) Report {
+// Prints the outline's header and its steps, placeholders and all.
+func (so *scenario_outline) Execute(x *execution, output io.Writer) Report {
+    if output != nil {
+        fmt.Fprintf(output, "%s\n", so.orig)
+        for _, stp := range so.steps {
+            fmt.Fprintf(output, "%s%s\n", stp.orig, stp.argument)
+        }
+    }
     return Report{}
 }
 
@@ -61,24 +83,36 @@ func (uls *printable_line) Last() *step {
     return nil
 }
 
-func (uls *printable_line)Execute(steps []stepdef, output io.Writer) Report {
+func (uls *printable_line)Execute(x *execution, output io.Writer) Report {
     if output != nil {
         fmt.Fprintf(output, "%s\n", uls.line)
     }
     return Report{}
 }
 
-func (uls *printable_line) IsBackground() bool {
-    return false
+func (scen *printable_line) IsJustPrintable() bool { return true }
+
+// Stands in for a scenario excluded by the runner's tag filter.
+type filtered_scenario struct {
 }
 
-func (scen *printable_line) IsJustPrintable() bool { return true }
+func (fs *filtered_scenario) AddStep(s step) {
+}
+
+func (fs *filtered_scenario) Last() *step {
+    return nil
+}
 
-type Scenario interface {
+func (fs *filtered_scenario) Execute(x *execution, output io.Writer) Report {
+    return Report{scenarioCount: 1, skippedScenarios: 1}
+}
+
+func (fs *filtered_scenario) IsJustPrintable() bool { return true }
+
+type executable interface {
     AddStep(step)
     Last() *step
-    Execute([]stepdef, io.Writer) Report
-    IsBackground() bool
+    Execute(*execution, io.Writer) Report
     IsJustPrintable() bool
 }
 
@@ -86,7 +120,27 @@ type scenario struct {
     steps []step
     isPending bool
     orig string
-    isBackground bool
+    backgrounds []*scenario
+    info *ScenarioInfo
+    // For scenarios generated from an outline, the example row which is
+    // printed in place of the steps.
+    row string
+}
+
+func backgroundFromAST(bg *Background) *scenario {
+    s := &scenario{orig: "  " + bg.Keyword + ": " + bg.Name}
+    for _, stp := range stepsFromAST(bg.Steps) {
+        s.AddStep(stp)
+    }
+    return s
+}
+
+func scenarioFromAST(def *Scenario) *scenario {
+    s := &scenario{orig: "  " + def.Keyword + ": " + def.Name}
+    for _, stp := range stepsFromAST(def.Steps) {
+        s.AddStep(stp)
+    }
+    return s
 }
 
 func (scen *scenario) IsJustPrintable() bool { return false }
@@ -106,50 +160,143 @@ func (s *scenario) Last() *step {
     return nil
 }
 
-func (s *scenario) Execute(stepdefs []stepdef, output io.Writer) Report {
+const (
+    colorPassed = "\033[32m"
+    colorFailed = "\033[31m"
+    colorPending = "\033[33m"
+    colorSkipped = "\033[36m"
+    colorReset = "\033[0m"
+)
+
+// The colour of an example row, as in Cucumber's pretty formatter.
+func rowColor(rpt Report) string {
+    if rpt.failedSteps > 0 || rpt.ambiguousSteps > 0 || rpt.hookFailures > 0 {
+        return colorFailed
+    } else if rpt.pendingSteps > 0 || rpt.undefinedSteps > 0 {
+        return colorPending
+    } else if rpt.passedSteps == 0 {
+        return colorSkipped
+    }
+    return colorPassed
+}
+
+func (s *scenario) Execute(x *execution, output io.Writer) Report {
+    if len(s.row) > 0 {
+        return s.executeAsRow(x, output)
+    }
+    return s.execute(x, output)
+}
+
+// Prints the example row, coloured by its result if colour is on. The
+// details of each step are only printed if the row did not pass.
+func (s *scenario) executeAsRow(x *execution, output io.Writer) Report {
+    details := &bytes.Buffer{}
+    rpt := s.execute(x, details)
+    if output != nil {
+        color := rowColor(rpt)
+        if x.color {
+            fmt.Fprintf(output, "%s%s%s\n", color, s.row, colorReset)
+        } else {
+            fmt.Fprintf(output, "%s\n", s.row)
+        }
+        if color != colorPassed {
+            fmt.Fprintf(output, "%s", details)
+        }
+    }
+    return rpt
+}
+
+// Runs the backgrounds (the Feature's, then the Rule's) and then the
+// scenario's own steps. Once a step is pending, ambiguous or fails, every
+// later step is skipped, as is every step if a BeforeScenario hook or the
+// world factory failed. Every step sees the same state, which is discarded
+// with the scenario.
+func (s *scenario) execute(x *execution, output io.Writer) Report {
     rpt := Report{}
+    isPending := x.skipSteps
+    state := newScenarioState(x.ctx, s.info)
+    if err := state.makeWorld(x.worldFactory, output); err != nil {
+        rpt.hookFailures++
+        isPending = true
+        if s.info != nil && s.info.Result != nil {
+            s.info.Result.Errors = append(s.info.Result.Errors, err)
+        }
+    }
+    for _, bg := range s.backgrounds {
+        isPending = bg.executeSteps(x, output, state, isPending, &rpt)
+    }
+    s.executeSteps(x, output, state, isPending, &rpt)
+    return rpt
+}
+
+func (s *scenario) executeSteps(x *execution, output io.Writer, state *scenarioState, isPending bool, rpt *Report) bool {
     if output != nil {
-        fmt.Fprintf(output, s.orig + "\n")
+        fmt.Fprintf(output, "%s\n", s.orig)
     }
-    isPending := false
     for _, line := range s.steps {
-        stepIsFound := true
+        // Once the run is cancelled, the remaining steps are skipped.
+        if x.ctx != nil && x.ctx.Err() != nil {
+            isPending = true
+        }
+        info := line.info(state)
+        if !isPending && x.hooks != nil {
+            rpt.hookFailures += x.hooks.callBeforeStep(info, output)
+        }
+        status := StepSkipped
         if !isPending {
-            stepIsFound = line.executeStepDef(stepdefs)
+            status = line.execute(x, state)
         }
-        if !isPending && line.isPending {
+        switch status {
+        case StepPending:
             rpt.pendingSteps++
+            isPending = true
             if output != nil {
-                fmt.Fprintf(output, "PENDING - %s\n", line.orig)
+                fmt.Fprintf(output, "PENDING - %s", line.orig)
             }
-            isPending = true
-        } else if isPending {
+        case StepSkipped:
             rpt.skippedSteps++
             if output != nil {
-                fmt.Fprintf(output, "Skipped - %s\n", line.orig)
+                fmt.Fprintf(output, "Skipped - %s", line.orig)
             }
-        } else if !stepIsFound {
-                rpt.undefinedSteps++
-                if output != nil {
-                    fmt.Fprintf(output, "UNDEFINED - %s\n", line.orig)
-                }
-        } else {
-            if line.hasErrors {
-                rpt.failedSteps++
-            } else {
-                rpt.passedSteps++
+        case StepUndefined:
+            rpt.undefinedSteps++
+            if output != nil {
+                fmt.Fprintf(output, "UNDEFINED - %s", line.orig)
+            }
+        case StepAmbiguous:
+            rpt.ambiguousSteps++
+            isPending = true
+            if output != nil {
+                fmt.Fprintf(output, "AMBIGUOUS - %s", line.orig)
+            }
+        case StepFailed:
+            rpt.failedSteps++
+            isPending = true
+            if output != nil {
+                fmt.Fprintf(output, "%s", line.orig)
             }
+        default:
+            rpt.passedSteps++
             if output != nil {
                 fmt.Fprintf(output, "%s", line.orig)
             }
         }
+        if status != StepSkipped {
+            info.Context = state.ctx
+            info.Status = status
+            if status != StepPassed && line.errors.Len() > 0 {
+                info.Err = errors.New(strings.TrimSpace(line.errors.String()))
+            }
+            if info.Err != nil && state.info != nil && state.info.Result != nil {
+                state.info.Result.Errors = append(state.info.Result.Errors, info.Err)
+            }
+            if x.hooks != nil {
+                rpt.hookFailures += x.hooks.callAfterStep(info, output)
+            }
+        }
         if output != nil {
-            fmt.Fprintf(output, "\n\t%v\n", &line.errors)
+            fmt.Fprintf(output, "%s\n\t%v\n", line.argument, &line.errors)
         }
     }
-    return rpt
-}
-
-func (s *scenario) IsBackground() bool {
-    return s.isBackground
+    return isPending
 }
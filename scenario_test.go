@@ -11,7 +11,7 @@ func TestReportsNumberOfPendingSteps(t *testing.T) {
     scen.AddStep(step{line:".", isPending:true})
     regex, _ := regexp.Compile(".")
     sd := stepdef{r:regex, f:func(w *World){ }}
-    rpt := scen.Execute([]stepdef{sd}, nil)
+    rpt := scen.Execute(&execution{steps: []stepdef{sd}}, nil)
 
     AssertThat(t, rpt.pendingSteps, Equals(1))
 }
@@ -22,7 +22,7 @@ func TestReportsNumberOfSkippedSteps(t *testing.T) {
     scen.AddStep(step{line:".", isPending:true})
     regex, _ := regexp.Compile(".")
     sd := stepdef{r:regex, f:func(w *World){ }}
-    rpt := scen.Execute([]stepdef{sd}, nil)
+    rpt := scen.Execute(&execution{steps: []stepdef{sd}}, nil)
 
     AssertThat(t, rpt.skippedSteps, Equals(1))
 }
@@ -32,7 +32,7 @@ func TestReportsNumberOfPassedSteps(t *testing.T) {
     scen.AddStep(step{line:"."})
     regex, _ := regexp.Compile(".")
     sd := stepdef{r:regex, f:func(w *World){ }}
-    rpt := scen.Execute([]stepdef{sd}, nil)
+    rpt := scen.Execute(&execution{steps: []stepdef{sd}}, nil)
 
     AssertThat(t, rpt.passedSteps, Equals(1))
 }
@@ -42,7 +42,7 @@ func TestReportsNumberOfFailedSteps(t *testing.T) {
     scen.AddStep(step{line:"."})
     regex, _ := regexp.Compile(".")
     sd := stepdef{r:regex, f:func(w *World){ AssertThat(w, true, IsFalse) }}
-    rpt := scen.Execute([]stepdef{sd}, nil)
+    rpt := scen.Execute(&execution{steps: []stepdef{sd}}, nil)
 
     AssertThat(t, rpt.failedSteps, Equals(1))
 }
@@ -50,7 +50,7 @@ func TestReportsNumberOfFailedSteps(t *testing.T) {
 func TestReportsNumberOfUndefinedSteps(t *testing.T) {
     scen := &scenario{}
     scen.AddStep(step{line:"."})
-    rpt := scen.Execute([]stepdef{}, nil)
+    rpt := scen.Execute(&execution{}, nil)
 
     AssertThat(t, rpt.undefinedSteps, Equals(1))
 }
@@ -0,0 +1,80 @@
+package gherkin
+
+import (
+    "fmt"
+    re "regexp"
+    "strconv"
+    "strings"
+)
+
+// Quoted strings and numbers in an undefined step become capture groups
+// in its snippet, in the order they are found here.
+var snippetArgument = re.MustCompile(`"[^"]*"|-?\d+(?:\.\d+)?`)
+
+// The Go code for a step definition matching an undefined step, e.g.
+//
+//     gherkin.Given(`^I have (\d+) apples$`, func(w *gherkin.World) {
+//         gherkin.Pending()
+//     })
+func snippetFor(stp *step) string {
+    pattern := "^"
+    last := 0
+    for _, loc := range snippetArgument.FindAllStringIndex(stp.line, -1) {
+        pattern += re.QuoteMeta(stp.line[last:loc[0]])
+        arg := stp.line[loc[0]:loc[1]]
+        switch {
+        case strings.HasPrefix(arg, `"`):
+            pattern += `"([^"]*)"`
+        case strings.Contains(arg, "."):
+            pattern += `(-?\d+\.\d+)`
+        case strings.HasPrefix(arg, "-"):
+            pattern += `(-?\d+)`
+        default:
+            pattern += `(\d+)`
+        }
+        last = loc[1]
+    }
+    pattern += re.QuoteMeta(stp.line[last:]) + "$"
+    return fmt.Sprintf("gherkin.%s(%s, func(w *gherkin.World) {\n    gherkin.Pending()\n})\n", snippetKeyword(stp.keywordType), quoteSnippet(pattern))
+}
+
+// Snippets use gherkin.When() and gherkin.Then() for actions and
+// outcomes, including the And and But steps which follow them, and
+// gherkin.Given() for anything else.
+func snippetKeyword(kt KeywordType) string {
+    switch kt {
+    case ActionKeyword:
+        return "When"
+    case OutcomeKeyword:
+        return "Then"
+    }
+    return "Given"
+}
+
+func quoteSnippet(pattern string) string {
+    if strings.Contains(pattern, "`") {
+        return strconv.Quote(pattern)
+    }
+    return "`" + pattern + "`"
+}
+
+// The distinct snippets for the undefined steps of a run, in the order
+// the steps were found.
+type snippets struct {
+    seen map[string]bool
+    list []string
+}
+
+func (s *snippets) add(snippet string) {
+    if s.seen == nil {
+        s.seen = map[string]bool{}
+    }
+    if !s.seen[snippet] {
+        s.seen[snippet] = true
+        s.list = append(s.list, snippet)
+    }
+}
+
+func (s *snippets) String() string {
+    return strings.Join(s.list, "\n")
+}
@@ -0,0 +1,75 @@
+package gherkin
+
+import (
+    "bytes"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    . "github.com/tychofreeman/go-matchers"
+)
+
+func snippetForText(kt KeywordType, text string) string {
+    stp := StepFromString(text)
+    stp.keywordType = kt
+    return snippetFor(&stp)
+}
+
+func TestSnippetTurnsNumbersIntoCaptureGroups(t *testing.T) {
+    AssertThat(t, snippetForText(ContextKeyword, "I have 12 apples"), Equals(
+        "gherkin.Given(`^I have (\\d+) apples$`, func(w *gherkin.World) {\n    gherkin.Pending()\n})\n"))
+}
+
+func TestSnippetTurnsQuotedStringsIntoCaptureGroups(t *testing.T) {
+    AssertThat(t, snippetForText(ActionKeyword, `I eat "2 red" apples at -1.5 each`), Equals(
+        "gherkin.When(`^I eat \"([^\"]*)\" apples at (-?\\d+\\.\\d+) each$`, func(w *gherkin.World) {\n    gherkin.Pending()\n})\n"))
+}
+
+func TestSnippetEscapesRegexpCharacters(t *testing.T) {
+    AssertThat(t, strings.Contains(snippetForText(OutcomeKeyword, "it costs $5 (or so)"), "gherkin.Then(`^it costs \\$(\\d+) \\(or so\\)$`"), IsTrue)
+}
+
+func TestSnippetUsesGivenForUnknownKeywords(t *testing.T) {
+    AssertThat(t, strings.HasPrefix(snippetForText(UnknownKeyword, "more"), "gherkin.Given("), IsTrue)
+    AssertThat(t, strings.HasPrefix(snippetForText("", "more"), "gherkin.Given("), IsTrue)
+}
+
+func TestSnippetQuotesPatternsContainingBackquotes(t *testing.T) {
+    AssertThat(t, strings.HasPrefix(snippetForText(ContextKeyword, "a `b`"), "gherkin.Given(\"^a `b`$\""), IsTrue)
+}
+
+func TestCollectsEachDistinctSnippetOnce(t *testing.T) {
+    g := createWriterlessRunner()
+    g.RegisterStepDef("^I am defined$", func(w *World) { })
+    g.Execute(`Feature:
+        Scenario:
+            Given I have 3 apples
+            And I am defined
+        Scenario:
+            Given I have 5 apples
+            Then I have 2 apples
+            And I have "none" left
+    `)
+
+    AssertThat(t, len(g.snippets.list), Equals(3))
+    AssertThat(t, strings.HasPrefix(g.snippets.list[0], "gherkin.Given(`^I have (\\d+) apples$`"), IsTrue)
+    AssertThat(t, strings.HasPrefix(g.snippets.list[2], "gherkin.Then(`^I have \"([^\"]*)\" left$`"), IsTrue)
+}
+
+func TestReportsSnippetsToOutputAndFile(t *testing.T) {
+    var buf bytes.Buffer
+    path := filepath.Join(t.TempDir(), "steps.go.txt")
+    g := createWriterlessRunner()
+    g.SetSnippetFile(path)
+    g.Execute(`Feature:
+        Scenario:
+            Given I have 3 apples
+    `)
+    g.SetOutput(&buf)
+    err := g.reportSnippets()
+
+    AssertThat(t, err == nil, IsTrue)
+    AssertThat(t, strings.Contains(buf.String(), "gherkin.Given(`^I have (\\d+) apples$`"), IsTrue)
+    written, _ := os.ReadFile(path)
+    AssertThat(t, string(written), Equals(g.snippets.String()))
+}
@@ -3,14 +3,24 @@ package gherkin
 import (
     "bytes"
     "fmt"
+    "runtime/debug"
+    "strings"
 )
 
 type step struct {
     line string
     orig string
+    // The keyword's type, or for a conjunction the type of the step
+    // before it.
+    keywordType KeywordType
     keys []string
     mldata []map[string]string
+    argument string
+    docString *DocString
     isPending bool
+    isAmbiguous bool
+    keyword string
+    location Location
     errors bytes.Buffer
     hasErrors bool
 }
@@ -27,30 +37,149 @@ func StepFromStringAndOrig(in, orig string) step{
     return step{ line : in, orig: orig, keys: []string{}, mldata : []map[string]string{} }
 }
 
+func stepFromAST(s *Step) step {
+    stp := StepFromStringAndOrig(s.Text, "    " + s.Keyword + s.Text)
+    stp.keyword = s.Keyword
+    stp.location = s.Location
+    if s.DataTable != nil {
+        for i, row := range s.DataTable.Rows {
+            if i == 0 {
+                stp.setMlKeys(row.Values())
+            } else {
+                stp.addMlData(createTableMap(stp.keys, row.Values()))
+            }
+            stp.argument += "\n" + formatTableRow("      ", row)
+        }
+    }
+    if s.DocString != nil {
+        stp.docString = s.DocString
+        stp.argument += "\n      " + s.DocString.Delimiter + s.DocString.ContentType
+        for _, l := range strings.Split(s.DocString.Content, "\n") {
+            stp.argument += "\n      " + l
+        }
+        stp.argument += "\n      " + s.DocString.Delimiter
+    }
+    return stp
+}
+
+// The steps of a scenario or background, with their effective keyword
+// types: an And or But step has the type of the step before it.
+func stepsFromAST(steps []*Step) []step {
+    result := []step{}
+    previous := UnknownKeyword
+    for _, s := range steps {
+        stp := stepFromAST(s)
+        stp.keywordType = s.KeywordType
+        if s.KeywordType == ConjunctionKeyword {
+            stp.keywordType = previous
+        }
+        previous = stp.keywordType
+        result = append(result, stp)
+    }
+    return result
+}
+
+// A copy of the step with outline placeholders replaced everywhere: in
+// its text, its table and its doc string.
+func (s step) withExample(replacer *strings.Replacer) step {
+    stp := StepFromStringAndOrig(replacer.Replace(s.line), replacer.Replace(s.orig))
+    stp.keywordType = s.keywordType
+    stp.keyword = s.keyword
+    stp.location = s.location
+    for _, k := range s.keys {
+        stp.keys = append(stp.keys, replacer.Replace(k))
+    }
+    for _, row := range s.mldata {
+        data := map[string]string{}
+        for k, v := range row {
+            data[replacer.Replace(k)] = replacer.Replace(v)
+        }
+        stp.addMlData(data)
+    }
+    if s.docString != nil {
+        ds := *s.docString
+        ds.Content = replacer.Replace(ds.Content)
+        stp.docString = &ds
+    }
+    stp.argument = replacer.Replace(s.argument)
+    return stp
+}
+
+func formatTableRow(indent string, row *TableRow) string {
+    return indent + "| " + strings.Join(row.Values(), " | ") + " |"
+}
+
 func (s *step) addMlData(line map[string]string) {
     s.mldata = append(s.mldata, line)
 }
 
+// A step definition which panics fails, unless it called Pending().
 func (s *step) recoverPending() {
     if rec := recover(); rec != nil {
         if rec == "Pending" {
             s.isPending = true
         } else {
-            panic(rec)
+            s.hasErrors = true
+            fmt.Fprintf(&s.errors, "panic: %v\n%s", rec, debug.Stack())
         }
     }
 }
 
-func (currStep *step) executeStepDef(steps []stepdef) bool {
+// Executes the step definition which matches the step. A step which more
+// than one definition matches is ambiguous, and none of them are called,
+// unless the runner allows the first match to be used.
+func (currStep *step) executeStepDef(x *execution, state *scenarioState) (found bool) {
     defer currStep.recoverPending()
-    for _, stepd := range steps {
-            //fmt.Printf("Executing step %s with stepdef %d (%v)\n", currStep, i, stepd)
-        if stepd.execute(currStep, &currStep.errors) {
-            return true
+    matches := []stepdef{}
+    for _, stepd := range x.steps {
+        if stepd.matches(currStep) && (!x.strictKeywords || stepd.acceptsKeyword(currStep.keywordType)) {
+            matches = append(matches, stepd)
+        }
+    }
+    if len(matches) == 0 {
+        fmt.Fprintf(&currStep.errors, `Could not find step definition for "%s"` + "\n", currStep.orig)
+        if x.snippets != nil {
+            x.snippets.add(snippetFor(currStep))
+        }
+        return false
+    }
+    if len(matches) > 1 && !x.allowAmbiguous {
+        currStep.isAmbiguous = true
+        fmt.Fprintf(&currStep.errors, `Ambiguous step definitions for "%s":` + "\n", currStep.orig)
+        for _, stepd := range matches {
+            fmt.Fprintf(&currStep.errors, "\t\t%s\n", stepd.describe())
         }
+        return true
+    }
+    // Still found if the step definition panics.
+    found = true
+    if !x.dryRun {
+        matches[0].execute(currStep, state, &currStep.errors)
     }
-    fmt.Fprintf(&currStep.errors, `Could not find step definition for "%s"` + "\n", currStep.orig)
-    return false
+    return
+}
+
+// Executes the step definition which matches the step, and tells how it
+// went. In a dry run, a step with a single match is skipped.
+func (s *step) execute(x *execution, state *scenarioState) StepStatus {
+    found := s.executeStepDef(x, state)
+    switch {
+    case s.isPending:
+        return StepPending
+    case !found:
+        return StepUndefined
+    case s.isAmbiguous:
+        return StepAmbiguous
+    case x.dryRun:
+        return StepSkipped
+    case s.hasErrors:
+        return StepFailed
+    }
+    return StepPassed
+}
+
+func (s *step) info(state *scenarioState) *StepInfo {
+    return &StepInfo{Keyword: s.keyword, Text: s.line, Location: s.location, Scenario: state.info, Context: state.ctx}
 }
 
 func (s *step) setMlKeys(keys []string) {
@@ -0,0 +1,201 @@
+package gherkin
+
+import (
+    "context"
+    "fmt"
+    "reflect"
+    re "regexp"
+    "strconv"
+    "time"
+)
+
+var worldType = reflect.TypeOf(&World{})
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Step definitions are either func(*World), which reads its captures
+// with World.GetRegexParam(), or take one argument per capture group,
+// optionally preceded by *World:
+//
+//     func(w *World, count int, name string, price float64)
+//
+// An argument filled by a parameter type with a transformer must accept
+// what the transformer returns. Step definitions may also take the
+// scenario's context.Context first, and return the context for the next
+// step, along with an error which fails the step:
+//
+//     func(ctx context.Context, count int) (context.Context, error)
+//
+// or return just an error.
+func validateStepFunc(r *re.Regexp, f interface{}, params []*parameterType) error {
+    if f == nil {
+        return nil
+    }
+    ft := reflect.TypeOf(f)
+    if ft.Kind() != reflect.Func {
+        return fmt.Errorf("step definition for %q must be a function, not %v", r, ft)
+    }
+    if !hasValidResults(ft) {
+        return fmt.Errorf("step definition for %q must return nothing, an error, a context.Context or (context.Context, error)", r)
+    }
+    args := stepParams(ft)
+    if len(args) == 0 && takesWorld(ft) {
+        return nil
+    }
+    if len(args) != r.NumSubexp() {
+        return fmt.Errorf("step definition for %q takes %d arguments but the pattern has %d capture groups", r, len(args), r.NumSubexp())
+    }
+    for i, t := range args {
+        if i < len(params) && params[i].typ != nil {
+            if !params[i].typ.AssignableTo(t) {
+                return fmt.Errorf("step definition for %q takes %v for argument %d but {%s} gives %v", r, t, i+1, params[i].name, params[i].typ)
+            }
+        } else if !isConvertible(t) {
+            return fmt.Errorf("step definition for %q has an argument of unsupported type %v", r, t)
+        }
+    }
+    return nil
+}
+
+func hasValidResults(ft reflect.Type) bool {
+    switch ft.NumOut() {
+    case 0:
+        return true
+    case 1:
+        return ft.Out(0) == contextType || ft.Out(0) == errorType
+    case 2:
+        return ft.Out(0) == contextType && ft.Out(1) == errorType
+    }
+    return false
+}
+
+// How many of the leading arguments are the context.Context and *World,
+// in that order, rather than captures.
+func leadingArgs(ft reflect.Type) (takesContext bool, n int) {
+    if n < ft.NumIn() && ft.In(n) == contextType {
+        takesContext = true
+        n++
+    }
+    if n < ft.NumIn() && ft.In(n) == worldType {
+        n++
+    }
+    return
+}
+
+func takesWorld(ft reflect.Type) bool {
+    takesContext, n := leadingArgs(ft)
+    return takesContext && n == 2 || !takesContext && n == 1
+}
+
+// The types of the arguments which are filled from capture groups.
+func stepParams(ft reflect.Type) []reflect.Type {
+    params := []reflect.Type{}
+    _, n := leadingArgs(ft)
+    for i := n; i < ft.NumIn(); i++ {
+        params = append(params, ft.In(i))
+    }
+    return params
+}
+
+func isConvertible(t reflect.Type) bool {
+    switch t.Kind() {
+    case reflect.String, reflect.Bool, reflect.Float32, reflect.Float64,
+        reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+        reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        return true
+    }
+    return false
+}
+
+func convertCapture(capture interface{}, t reflect.Type) (reflect.Value, error) {
+    if s, ok := capture.(string); ok {
+        return convertArg(s, t)
+    }
+    v := reflect.ValueOf(capture)
+    if !v.IsValid() || !v.Type().AssignableTo(t) {
+        return reflect.New(t).Elem(), fmt.Errorf("%T is not assignable to %v", capture, t)
+    }
+    return v, nil
+}
+
+func convertArg(value string, t reflect.Type) (reflect.Value, error) {
+    v := reflect.New(t).Elem()
+    if t == durationType {
+        d, err := time.ParseDuration(value)
+        if err != nil {
+            return v, err
+        }
+        v.SetInt(int64(d))
+        return v, nil
+    }
+    switch t.Kind() {
+    case reflect.String:
+        v.SetString(value)
+    case reflect.Bool:
+        b, err := strconv.ParseBool(value)
+        if err != nil {
+            return v, err
+        }
+        v.SetBool(b)
+    case reflect.Float32, reflect.Float64:
+        f, err := strconv.ParseFloat(value, t.Bits())
+        if err != nil {
+            return v, err
+        }
+        v.SetFloat(f)
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        i, err := strconv.ParseInt(value, 10, t.Bits())
+        if err != nil {
+            return v, err
+        }
+        v.SetInt(i)
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        u, err := strconv.ParseUint(value, 10, t.Bits())
+        if err != nil {
+            return v, err
+        }
+        v.SetUint(u)
+    default:
+        return v, fmt.Errorf("unsupported type %v", t)
+    }
+    return v, nil
+}
+
+// Calls the step definition with each capture converted to the type of
+// its argument. A capture which cannot be converted fails the step.
+// Captures which a parameter type has already transformed are passed
+// as they are. A context which the step returns replaces the scenario's,
+// and an error which it returns fails the step.
+func callStepFunc(f interface{}, w *World, captures []interface{}) {
+    if legacy, ok := f.(func(*World)); ok {
+        legacy(w)
+        return
+    }
+    fv := reflect.ValueOf(f)
+    ft := fv.Type()
+    args := []reflect.Value{}
+    if takesContext, _ := leadingArgs(ft); takesContext {
+        args = append(args, reflect.ValueOf(w.Context()))
+    }
+    if takesWorld(ft) {
+        args = append(args, reflect.ValueOf(w))
+    }
+    for i, t := range stepParams(ft) {
+        v, err := convertCapture(captures[i], t)
+        if err != nil {
+            w.Errorf("cannot convert %#v to %v for argument %d: %v\n", captures[i], t, i+1, err)
+            return
+        }
+        args = append(args, v)
+    }
+    for i, result := range fv.Call(args) {
+        if result.IsNil() {
+            continue
+        }
+        if ft.Out(i) == contextType {
+            w.setContext(result.Interface().(context.Context))
+        } else {
+            w.Errorf("%v\n", result.Interface())
+        }
+    }
+}
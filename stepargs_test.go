@@ -0,0 +1,198 @@
+package gherkin
+
+import (
+    "context"
+    "errors"
+    "strings"
+    "testing"
+    "time"
+    . "github.com/tychofreeman/go-matchers"
+)
+
+func TestConvertsCapturesToArgumentTypes(t *testing.T) {
+    g := createWriterlessRunner()
+    var count int
+    var name string
+    var price float64
+    var fresh bool
+    var wait time.Duration
+    g.RegisterStepDef(`^(\d+) (\w+) at ([\d.]+) fresh=(\w+) after (\w+)$`, func(w *World, c int, n string, p float64, f bool, d time.Duration) {
+        count, name, price, fresh, wait = c, n, p, f, d
+    })
+    rpt := g.Execute(`Feature:
+        Scenario:
+            Given 3 apples at 1.25 fresh=true after 1m30s
+    `)
+
+    AssertThat(t, rpt.passedSteps, Equals(1))
+    AssertThat(t, count, Equals(3))
+    AssertThat(t, name, Equals("apples"))
+    AssertThat(t, price, Equals(1.25))
+    AssertThat(t, fresh, IsTrue)
+    AssertThat(t, wait, Equals(90 * time.Second))
+}
+
+func TestWorldArgumentIsOptional(t *testing.T) {
+    g := createWriterlessRunner()
+    var count uint8
+    g.RegisterStepDef(`^(\d+) apples$`, func(c uint8) { count = c })
+    g.Execute(`Feature:
+        Scenario:
+            Given 7 apples
+    `)
+
+    AssertThat(t, count, Equals(uint8(7)))
+}
+
+func TestConversionFailureFailsStepWithValue(t *testing.T) {
+    g := createWriterlessRunner()
+    wasCalled := false
+    g.RegisterStepDef(`^(\w+) apples$`, func(w *World, c int) { wasCalled = true })
+    scen := &scenario{}
+    scen.AddStep(StepFromString("many apples"))
+    rpt := scen.Execute(&execution{steps: g.steps}, nil)
+
+    AssertThat(t, rpt.failedSteps, Equals(1))
+    AssertThat(t, wasCalled, IsFalse)
+}
+
+func TestConversionFailureMessageNamesValue(t *testing.T) {
+    stp := StepFromString("many apples")
+    sd, _ := createstepdef(`^(\w+) apples$`, func(c int) { }, builtinParameterTypes)
+    sd.execute(&stp, nil, &stp.errors)
+
+    AssertThat(t, stp.hasErrors, IsTrue)
+    AssertThat(t, strings.Contains(stp.errors.String(), `cannot convert "many" to int`), IsTrue)
+}
+
+func registrationError(pattern string, f interface{}) error {
+    _, err := createstepdef(pattern, f, builtinParameterTypes)
+    return err
+}
+
+func TestRegistrationRejectsInvalidFunctions(t *testing.T) {
+    AssertThat(t, registrationError(`^(\d+)$`, "not a function") != nil, IsTrue)
+    AssertThat(t, registrationError(`^(\d+)$`, func(a, b int) { }) != nil, IsTrue)
+    AssertThat(t, registrationError(`^(\d+)$`, func(a []int) { }) != nil, IsTrue)
+    AssertThat(t, registrationError(`^(\d+)$`, func(a int) int { return a }) != nil, IsTrue)
+    AssertThat(t, registrationError(`^(\d+)$`, func(w *World) { }) == nil, IsTrue)
+}
+
+func TestGetRegexParamReturnsEachCaptureInTurn(t *testing.T) {
+    g := createWriterlessRunner()
+    captured := []string{}
+    g.RegisterStepDef("^(a) (b)$", func(w *World) {
+        captured = append(captured, w.GetRegexParam(), w.GetRegexParam())
+    })
+    g.Execute(`Feature:
+        Scenario:
+            Given a b
+    `)
+
+    AssertThat(t, captured, Equals([]string{"a", "b"}))
+}
+
+func TestRunReportsEachStepDefinitionWhichCouldNotBeRegistered(t *testing.T) {
+    g := createWriterlessRunner()
+    g.RegisterStepDef(`^I have (\d+ apples$`, func(w *World) { })
+    g.RegisterStepDef(`^I have (\d+) pears$`, func(w *World) { })
+    g.RegisterExpression(`I have {colour} plums`, func(w *World) { })
+    c := &errorCollector{}
+    g.Run(c)
+
+    AssertThat(t, len(c.messages), Equals(2))
+    AssertThat(t, strings.HasPrefix(c.messages[0], "stepargs_test.go:"), IsTrue)
+    AssertThat(t, strings.Contains(c.messages[0], `cannot register step definition "^I have (\\d+ apples$": error parsing regexp`), IsTrue)
+    AssertThat(t, strings.Contains(c.messages[1], `"I have {colour} plums"`), IsTrue)
+    AssertThat(t, len(g.steps), Equals(1))
+}
+
+func TestExecuteRunsNothingIfAStepDefinitionCouldNotBeRegistered(t *testing.T) {
+    g := createWriterlessRunner()
+    wasCalled := false
+    g.RegisterStepDef(`^I have (\d+) pears$`, func(w *World) { wasCalled = true })
+    g.RegisterStepDef(`^I have (\d+) apples$`, func(a, b int) { })
+    rpt := g.Execute(`Feature:
+        Scenario:
+            Given I have 3 pears
+    `)
+
+    AssertThat(t, wasCalled, IsFalse)
+    AssertThat(t, rpt.scenarioCount, Equals(0))
+}
+
+type basketKey struct{}
+
+func TestContextReturnedByAStepFlowsToTheNext(t *testing.T) {
+    g := createWriterlessRunner()
+    var seen []interface{}
+    g.RegisterStepDef(`^I put (\d+) apples in the basket$`, func(ctx context.Context, n int) context.Context {
+        return context.WithValue(ctx, basketKey{}, n)
+    })
+    g.RegisterStepDef(`^the basket has (\d+) apples$`, func(ctx context.Context, w *World, n int) (context.Context, error) {
+        seen = append(seen, ctx.Value(basketKey{}), w.Context().Value(basketKey{}))
+        return ctx, nil
+    })
+    g.Execute(`Feature:
+        Background:
+            Given I put 3 apples in the basket
+        Scenario:
+            Then the basket has 3 apples
+        Scenario:
+            Then the basket has 3 apples
+    `)
+
+    AssertThat(t, seen, Equals([]interface{}{3, 3, 3, 3}))
+}
+
+func TestErrorReturnedWithContextFailsTheStep(t *testing.T) {
+    g := createWriterlessRunner()
+    g.RegisterStepDef(`^it fails$`, func(ctx context.Context) (context.Context, error) {
+        return ctx, errors.New("it failed")
+    })
+    rpt := g.Execute(`Feature:
+        Scenario:
+            Then it fails
+    `)
+
+    AssertThat(t, rpt.failedSteps, Equals(1))
+}
+
+func TestCancellingTheRunSkipsTheRemainingSteps(t *testing.T) {
+    ctx, cancel := context.WithCancel(context.Background())
+    g := createWriterlessRunner()
+    g.ctx = ctx
+    calls := 0
+    g.RegisterStepDef(`^I cancel the run$`, func(ctx context.Context) { cancel() })
+    g.RegisterStepDef(`^I do something$`, func(w *World) { calls++ })
+    rpt := g.Execute(`Feature:
+        Scenario:
+            Given I do something
+            When I cancel the run
+            Then I do something
+        Scenario:
+            Given I do something
+    `)
+
+    AssertThat(t, calls, Equals(1))
+    AssertThat(t, rpt.passedSteps, Equals(2))
+    AssertThat(t, rpt.skippedSteps, Equals(2))
+}
+
+func TestRunContextFailsWhenCancelled(t *testing.T) {
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    c := &errorCollector{}
+    createWriterlessRunner().RunContext(ctx, c)
+
+    AssertThat(t, len(c.messages), Equals(1))
+}
+
+func TestRegistrationChecksWhatStepFunctionsReturn(t *testing.T) {
+    AssertThat(t, registrationError(`^(\d+)$`, func(ctx context.Context, n int) context.Context { return ctx }) == nil, IsTrue)
+    AssertThat(t, registrationError(`^(\d+)$`, func(ctx context.Context, w *World, n int) (context.Context, error) { return ctx, nil }) == nil, IsTrue)
+    AssertThat(t, registrationError(`^(\d+)$`, func(ctx context.Context, w *World) { }) == nil, IsTrue)
+    AssertThat(t, registrationError(`^(\d+)$`, func(n int) error { return nil }) == nil, IsTrue)
+    AssertThat(t, registrationError(`^(\d+)$`, func(ctx context.Context) { }) != nil, IsTrue)
+    AssertThat(t, registrationError(`^(\d+)$`, func(n int) (error, context.Context) { return nil, nil }) != nil, IsTrue)
+}
@@ -1,33 +1,147 @@
 package gherkin
 
 import (
+    "fmt"
     re "regexp"
+    "reflect"
     "io"
 )
 
 type stepdef struct {
     r *re.Regexp
-    f func(*World)
+    f interface{}
+    // The parameter type of each capture group, if the step definition
+    // was given as a Cucumber Expression.
+    params []*parameterType
+    pattern string
+    // Where the step definition was registered, as file:line.
+    location string
+    // The type of step it was registered for with Given(), When() or
+    // Then(). Empty if it was registered for any step.
+    keywordType KeywordType
 }
 
-func createstepdef(p string, f func(*World)) stepdef {
-    r, _ := re.Compile(p)
-    return stepdef{r, f}
+// Patterns which look like Cucumber Expressions are compiled as one,
+// anything else as a regular expression. The arguments of a regular
+// expression's step function may also be of any domain type, such as
+// Color, which a defined parameter type gives.
+func createstepdef(p string, f interface{}, types map[string]*parameterType) (stepdef, error) {
+    if looksLikeExpression(p, types) {
+        return createexpressionstepdef(p, f, types)
+    }
+    r, err := re.Compile(p)
+    if err != nil {
+        return stepdef{}, err
+    }
+    params, err := regexpParams(f, types)
+    if err != nil {
+        return stepdef{}, err
+    }
+    if err := validateStepFunc(r, f, params); err != nil {
+        return stepdef{}, err
+    }
+    return stepdef{r: r, f: f, params: params, pattern: p}, nil
+}
+
+// Arguments of a type which no parameter type gives are left to
+// convertArg(), so a nil transform is left in their place.
+func regexpParams(f interface{}, types map[string]*parameterType) ([]*parameterType, error) {
+    ft := reflect.TypeOf(f)
+    if ft == nil || ft.Kind() != reflect.Func {
+        return nil, nil
+    }
+    params := []*parameterType{}
+    for _, t := range stepParams(ft) {
+        pt, err := parameterTypeFor(t, types)
+        if err != nil {
+            return nil, err
+        }
+        if pt == nil {
+            pt = &parameterType{}
+        }
+        params = append(params, pt)
+    }
+    return params, nil
 }
 
-func (s stepdef) execute(line *step, output io.Writer) bool {
-    if s.r.MatchString(line.String()) {
+func createexpressionstepdef(expr string, f interface{}, types map[string]*parameterType) (stepdef, error) {
+    p, params, err := compileExpression(expr, types)
+    if err != nil {
+        return stepdef{}, err
+    }
+    r, err := re.Compile(p)
+    if err != nil {
+        return stepdef{}, err
+    }
+    if err := validateStepFunc(r, f, params); err != nil {
+        return stepdef{}, err
+    }
+    return stepdef{r: r, f: f, params: params, pattern: expr}, nil
+}
+
+// Transforms each capture according to its parameter type. Also returns
+// the captures as text, for World.GetRegexParam().
+func (s stepdef) arguments(captures []string) ([]interface{}, []string, error) {
+    values := make([]interface{}, len(captures))
+    texts := make([]string, len(captures))
+    for i, c := range captures {
+        values[i], texts[i] = c, c
+        if i < len(s.params) && s.params[i].transform != nil {
+            v, err := s.params[i].transform(c)
+            if err != nil {
+                return nil, nil, fmt.Errorf("cannot transform %q to {%s}: %v", c, s.params[i].name, err)
+            }
+            values[i] = v
+            if str, ok := v.(string); ok {
+                texts[i] = str
+            }
+        }
+    }
+    return values, texts, nil
+}
+
+func (s stepdef) matches(line *step) bool {
+    return s.r.MatchString(line.String())
+}
+
+// Whether the step definition may be used for a step of the given type,
+// when the runner matches keywords strictly. Steps whose type is unknown,
+// such as "* I have 3 apples", may use any step definition.
+func (s stepdef) acceptsKeyword(kt KeywordType) bool {
+    return s.keywordType == "" || kt == "" || kt == UnknownKeyword || s.keywordType == kt
+}
+
+func (s stepdef) execute(line *step, state *scenarioState, output io.Writer) bool {
+    if s.matches(line) {
         if s.f != nil {
             substrs := s.r.FindStringSubmatch(line.String())
-            w := &World{regexParams:substrs, MultiStep:line.mldata, output: output} 
+            w := &World{regexParams:substrs, MultiStep:line.mldata, docString:line.docString, scenario: state, output: output}
             defer func() { line.hasErrors = w.gotAnError }()
-            s.f(w)
+            values, texts, err := s.arguments(substrs[1:])
+            if err != nil {
+                w.Errorf("%v\n", err)
+                return true
+            }
+            w.regexParams = append([]string{substrs[0]}, texts...)
+            callStepFunc(s.f, w, values)
         }
         return true
     }
     return false
 }
 
+// The pattern along with where it was registered, for messages which
+// need to point at a step definition.
+func (s stepdef) describe() string {
+    if len(s.location) > 0 {
+        return s.String() + " (" + s.location + ")"
+    }
+    return s.String()
+}
+
 func (s stepdef) String() string {
+    if len(s.pattern) > 0 {
+        return s.pattern
+    }
     return s.r.String()
 }
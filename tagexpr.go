@@ -0,0 +1,220 @@
+package gherkin
+
+import (
+    "fmt"
+    "unicode"
+)
+
+// A parsed Cucumber tag expression, such as "@smoke and not (@slow or @wip)".
+type tagExpression interface {
+    matches(tags []string) bool
+}
+
+type tagLiteral struct {
+    name string
+}
+
+func (l tagLiteral) matches(tags []string) bool {
+    for _, t := range tags {
+        if t == l.name {
+            return true
+        }
+    }
+    return false
+}
+
+type tagAnd struct {
+    left, right tagExpression
+}
+
+func (e tagAnd) matches(tags []string) bool {
+    return e.left.matches(tags) && e.right.matches(tags)
+}
+
+type tagOr struct {
+    left, right tagExpression
+}
+
+func (e tagOr) matches(tags []string) bool {
+    return e.left.matches(tags) || e.right.matches(tags)
+}
+
+type tagNot struct {
+    expr tagExpression
+}
+
+func (e tagNot) matches(tags []string) bool {
+    return !e.expr.matches(tags)
+}
+
+// What an empty expression parses to.
+type tagAlways struct{}
+
+func (tagAlways) matches(tags []string) bool {
+    return true
+}
+
+type tagToken struct {
+    text string
+    // Escaped tokens are always tag names, never operators or parentheses.
+    escaped bool
+}
+
+func (t tagToken) is(op string) bool {
+    return !t.escaped && t.text == op
+}
+
+func tagExpressionError(expr, format string, args ...interface{}) error {
+    return fmt.Errorf("Tag expression %q could not be parsed because of syntax error: %s", expr, fmt.Sprintf(format, args...))
+}
+
+// Splits on whitespace and parentheses. A backslash escapes whitespace,
+// parentheses and itself.
+func tokenizeTagExpression(expr string) ([]tagToken, error) {
+    tokens := []tagToken{}
+    var text []rune
+    escaped, isEscape := false, false
+    flush := func() {
+        if len(text) > 0 {
+            tokens = append(tokens, tagToken{string(text), escaped})
+        }
+        text = nil
+        escaped = false
+    }
+    for _, c := range expr {
+        if isEscape {
+            if c != '(' && c != ')' && c != '\\' && !unicode.IsSpace(c) {
+                return nil, tagExpressionError(expr, "Illegal escape before %q.", c)
+            }
+            text = append(text, c)
+            isEscape = false
+        } else if c == '\\' {
+            isEscape = true
+            escaped = true
+        } else if c == '(' || c == ')' {
+            flush()
+            tokens = append(tokens, tagToken{string(c), false})
+        } else if unicode.IsSpace(c) {
+            flush()
+        } else {
+            text = append(text, c)
+        }
+    }
+    if isEscape {
+        return nil, tagExpressionError(expr, "Illegal escape at end of expression.")
+    }
+    flush()
+    return tokens, nil
+}
+
+func tagPrecedence(op string) int {
+    switch op {
+    case "or":
+        return 0
+    case "and":
+        return 1
+    case "not":
+        return 2
+    }
+    return -1
+}
+
+// Parses the expression with the shunting-yard algorithm. 'or' binds
+// loosest, then 'and', then 'not'.
+func parseTagExpression(expr string) (tagExpression, error) {
+    tokens, err := tokenizeTagExpression(expr)
+    if err != nil {
+        return nil, err
+    }
+    if len(tokens) == 0 {
+        return tagAlways{}, nil
+    }
+
+    operands := []tagExpression{}
+    operators := []string{}
+    apply := func(op string) error {
+        if op == "not" {
+            if len(operands) < 1 {
+                return tagExpressionError(expr, "Expected operand.")
+            }
+            operands[len(operands)-1] = tagNot{operands[len(operands)-1]}
+            return nil
+        }
+        if len(operands) < 2 {
+            return tagExpressionError(expr, "Expected operand.")
+        }
+        left, right := operands[len(operands)-2], operands[len(operands)-1]
+        operands = operands[:len(operands)-2]
+        if op == "and" {
+            operands = append(operands, tagAnd{left, right})
+        } else {
+            operands = append(operands, tagOr{left, right})
+        }
+        return nil
+    }
+    pop := func() string {
+        op := operators[len(operators)-1]
+        operators = operators[:len(operators)-1]
+        return op
+    }
+
+    expectOperand := true
+    for _, tok := range tokens {
+        switch {
+        case tok.is("("):
+            if !expectOperand {
+                return nil, tagExpressionError(expr, "Expected operator.")
+            }
+            operators = append(operators, "(")
+        case tok.is(")"):
+            if expectOperand {
+                return nil, tagExpressionError(expr, "Expected operand.")
+            }
+            for len(operators) > 0 && operators[len(operators)-1] != "(" {
+                if err := apply(pop()); err != nil {
+                    return nil, err
+                }
+            }
+            if len(operators) == 0 {
+                return nil, tagExpressionError(expr, "Unmatched ).")
+            }
+            pop()
+        case tok.is("not"):
+            if !expectOperand {
+                return nil, tagExpressionError(expr, "Expected operator.")
+            }
+            operators = append(operators, "not")
+        case tok.is("and") || tok.is("or"):
+            if expectOperand {
+                return nil, tagExpressionError(expr, "Expected operand.")
+            }
+            // Both are left-associative.
+            for len(operators) > 0 && tagPrecedence(operators[len(operators)-1]) >= tagPrecedence(tok.text) {
+                if err := apply(pop()); err != nil {
+                    return nil, err
+                }
+            }
+            operators = append(operators, tok.text)
+            expectOperand = true
+        default:
+            if !expectOperand {
+                return nil, tagExpressionError(expr, "Expected operator.")
+            }
+            operands = append(operands, tagLiteral{tok.text})
+            expectOperand = false
+        }
+    }
+    if expectOperand {
+        return nil, tagExpressionError(expr, "Expected operand.")
+    }
+    for len(operators) > 0 {
+        op := pop()
+        if op == "(" {
+            return nil, tagExpressionError(expr, "Unmatched (.")
+        }
+        if err := apply(op); err != nil {
+            return nil, err
+        }
+    }
+    return operands[0], nil
+}
@@ -0,0 +1,90 @@
+package gherkin
+
+import (
+    "strings"
+    "testing"
+    . "github.com/tychofreeman/go-matchers"
+)
+
+func tagExpressionMatches(t *testing.T, expr string, tags ...string) bool {
+    e, err := parseTagExpression(expr)
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    return e.matches(tags)
+}
+
+func TestEmptyTagExpressionMatchesEverything(t *testing.T) {
+    AssertThat(t, tagExpressionMatches(t, "  "), IsTrue)
+}
+
+func TestTagExpressionMatchesSingleTag(t *testing.T) {
+    AssertThat(t, tagExpressionMatches(t, "@a", "@a"), IsTrue)
+    AssertThat(t, tagExpressionMatches(t, "@a", "@b"), IsFalse)
+}
+
+func TestTagExpressionAndBindsTighterThanOr(t *testing.T) {
+    AssertThat(t, tagExpressionMatches(t, "@a or @b and @c", "@a"), IsTrue)
+    AssertThat(t, tagExpressionMatches(t, "@a or @b and @c", "@b"), IsFalse)
+    AssertThat(t, tagExpressionMatches(t, "(@a or @b) and @c", "@a"), IsFalse)
+}
+
+func TestTagExpressionNotBindsTighterThanAnd(t *testing.T) {
+    AssertThat(t, tagExpressionMatches(t, "@smoke and not @slow", "@smoke"), IsTrue)
+    AssertThat(t, tagExpressionMatches(t, "@smoke and not @slow", "@smoke", "@slow"), IsFalse)
+    AssertThat(t, tagExpressionMatches(t, "not @a and @b", "@b"), IsTrue)
+    AssertThat(t, tagExpressionMatches(t, "not (@a or @b)", "@b"), IsFalse)
+    AssertThat(t, tagExpressionMatches(t, "not not @a", "@a"), IsTrue)
+}
+
+func TestTagExpressionSupportsEscapes(t *testing.T) {
+    AssertThat(t, tagExpressionMatches(t, `@a\(1\) or @b\ c`, "@a(1)"), IsTrue)
+    AssertThat(t, tagExpressionMatches(t, `@a\(1\) or @b\ c`, "@b c"), IsTrue)
+    AssertThat(t, tagExpressionMatches(t, `@a\\b`, `@a\b`), IsTrue)
+}
+
+func tagExpressionErrorFor(t *testing.T, expr string) string {
+    _, err := parseTagExpression(expr)
+    if err == nil {
+        t.Fatalf("expected an error for %q", expr)
+    }
+    return err.Error()
+}
+
+func TestTagExpressionReportsSyntaxErrors(t *testing.T) {
+    AssertThat(t, strings.HasSuffix(tagExpressionErrorFor(t, "@a and"), "Expected operand."), IsTrue)
+    AssertThat(t, strings.HasSuffix(tagExpressionErrorFor(t, "@a @b"), "Expected operator."), IsTrue)
+    AssertThat(t, strings.HasSuffix(tagExpressionErrorFor(t, "(@a"), "Unmatched (."), IsTrue)
+    AssertThat(t, strings.HasSuffix(tagExpressionErrorFor(t, "@a)"), "Unmatched )."), IsTrue)
+    AssertThat(t, strings.HasSuffix(tagExpressionErrorFor(t, `@a\b`), `Illegal escape before 'b'.`), IsTrue)
+}
+
+func TestTagFilterSkipsNonMatchingScenarios(t *testing.T) {
+    g := createWriterlessRunner()
+    AssertThat(t, g.SetTagFilter("@smoke and not @slow"), Equals(nil))
+    ran := []string{}
+    g.RegisterStepDef(".", func(w *World) { ran = append(ran, w.Scenario().Name) })
+    rpt := g.Execute(`@smoke
+    Feature:
+        Scenario: fast
+            Given .
+        @slow
+        Scenario: slow
+            Given .
+        Scenario Outline: outline
+            Given <x>
+        @slow
+        Examples:
+            |x|
+            |a|
+    `)
+
+    AssertThat(t, ran, Equals([]string{"fast"}))
+    AssertThat(t, rpt.skippedScenarios, Equals(2))
+}
+
+func TestSetTagFilterReturnsSyntaxErrors(t *testing.T) {
+    g := createWriterlessRunner()
+
+    AssertThat(t, g.SetTagFilter("@a or or @b") != nil, IsTrue)
+}
@@ -1,6 +1,7 @@
 package gherkin
 
 import (
+    "context"
     "fmt"
     "io"
 )
@@ -10,12 +11,41 @@ type World struct {
     regexParams []string
     regexParamIndex int
     MultiStep []map[string]string
+    docString *DocString
+    scenario *scenarioState
     output io.Writer
     gotAnError bool
 }
 
+// What the steps of a single scenario, including its backgrounds, share.
+type scenarioState struct {
+    info *ScenarioInfo
+    values map[string]interface{}
+    world interface{}
+    // Starts as the run's context, and is replaced by whatever each step
+    // returns.
+    ctx context.Context
+}
+
+func newScenarioState(ctx context.Context, info *ScenarioInfo) *scenarioState {
+    if ctx == nil {
+        ctx = context.Background()
+    }
+    state := &scenarioState{info: info, values: map[string]interface{}{}}
+    state.setContext(ctx)
+    return state
+}
+
+// Like the set-up function, the world factory fails by panicking.
+func (s *scenarioState) makeWorld(worldFactory func() interface{}, output io.Writer) error {
+    if worldFactory == nil {
+        return nil
+    }
+    return callHook("WorldFactory", func() { s.world = worldFactory() }, output)
+}
+
 // Allows access to step definition regular expression captures.
-func (w World) GetRegexParam() string {
+func (w *World) GetRegexParam() string {
     w.regexParamIndex++
     if w.regexParamIndex >= len(w.regexParams) {
         panic("GetRegexParam() called too many times.")
@@ -23,10 +53,77 @@ func (w World) GetRegexParam() string {
     return w.regexParams[w.regexParamIndex]
 }
 
+// Allows access to the step's doc string, and to its media type such as
+// "json" (if any was given). Both are empty if the step has no doc string.
+func (w *World) DocString() (content, mediaType string) {
+    if w.docString == nil {
+        return "", ""
+    }
+    return w.docString.Content, w.docString.ContentType
+}
+
+// Describes the scenario which the step belongs to, including its tags.
+func (w *World) Scenario() *ScenarioInfo {
+    if w.scenario == nil {
+        return nil
+    }
+    return w.scenario.info
+}
+
+// Stores a value for the later steps of the same scenario to Get(). Each
+// scenario starts with nothing stored.
+func (w *World) Set(key string, value interface{}) {
+    if w.scenario == nil {
+        w.scenario = newScenarioState(nil, nil)
+    }
+    w.scenario.values[key] = value
+}
+
+// Returns the value which an earlier step of the scenario Set(), or nil.
+func (w *World) Get(key string) interface{} {
+    if w.scenario == nil {
+        return nil
+    }
+    return w.scenario.values[key]
+}
+
+// The scenario's context, as returned by its most recent step. It is
+// cancelled if the run is.
+func (w *World) Context() context.Context {
+    if w.scenario == nil {
+        return context.Background()
+    }
+    return w.scenario.ctx
+}
+
+func (w *World) setContext(ctx context.Context) {
+    if w.scenario == nil {
+        w.scenario = newScenarioState(nil, nil)
+    }
+    w.scenario.setContext(ctx)
+}
+
+// Keeps the scenario's info, as seen by hooks, up to date.
+func (s *scenarioState) setContext(ctx context.Context) {
+    s.ctx = ctx
+    if s.info != nil {
+        s.info.Context = ctx
+    }
+}
+
+// Returns what the runner's world factory made for this scenario, or nil
+// if it has none. See Runner.SetWorldFactory().
+func (w *World) State() interface{} {
+    if w.scenario == nil {
+        return nil
+    }
+    return w.scenario.world
+}
+
 // Allows World to be used with the go-matchers AssertThat() function.
 func (w *World) Errorf(format string, args ...interface{}) {
     w.gotAnError = true
     if w.output != nil {
-        fmt.Fprintf(w.output, format, args)
+        fmt.Fprintf(w.output, format, args...)
     }
 }